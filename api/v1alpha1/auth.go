@@ -0,0 +1,151 @@
+package v1alpha1
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// secretVariables are referenced from Secrets by spec.auth. Set in
+// spec.variables they are still passed in plain values, deprecated.
+var secretVariables = [][2]string{
+	{"JWT_APP_SECRET", "spec.auth.jwt.appSecret"},
+	{"LDAP_BINDDN", "spec.auth.ldap.bindSecret"},
+	{"LDAP_BINDPW", "spec.auth.ldap.bindSecret"},
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// envVarValue returns the value of the variable set by the auth, ok is false
+// when the variable is left to spec.variables
+func (auth *Auth) envVarValue(name string) (value string, ok bool) {
+	switch name {
+	case "ENABLE_AUTH":
+		return boolValue(auth.Type != AuthAnonymous), true
+	case "AUTH_TYPE":
+		if auth.Type == AuthAnonymous {
+			return "", true
+		}
+		return string(auth.Type), true
+	case "ENABLE_GUESTS":
+		return boolValue(auth.Guests), true
+	case "XMPP_GUEST_DOMAIN":
+		return auth.GuestDomain, len(auth.GuestDomain) > 0
+	}
+
+	if jwt := auth.JWT; jwt != nil && auth.Type == AuthJWT {
+		switch name {
+		case "JWT_APP_ID":
+			return jwt.AppID, true
+		case "JWT_ACCEPTED_ISSUERS":
+			return strings.Join(jwt.AcceptedIssuers, ","), len(jwt.AcceptedIssuers) > 0
+		case "JWT_ACCEPTED_AUDIENCES":
+			return strings.Join(jwt.AcceptedAudiences, ","), len(jwt.AcceptedAudiences) > 0
+		case "JWT_ASAP_KEYSERVER":
+			return jwt.ASAPKeyServer, len(jwt.ASAPKeyServer) > 0
+		case "JWT_ALLOW_EMPTY":
+			return boolValue(jwt.AllowEmpty), true
+		case "TOKEN_AUTH_URL":
+			return jwt.TokenAuthURL, len(jwt.TokenAuthURL) > 0
+		}
+	}
+
+	if ldap := auth.LDAP; ldap != nil && auth.Type == AuthLDAP {
+		switch name {
+		case "LDAP_URL":
+			return ldap.URL, true
+		case "LDAP_BASE":
+			return ldap.Base, len(ldap.Base) > 0
+		case "LDAP_FILTER":
+			return ldap.Filter, len(ldap.Filter) > 0
+		case "LDAP_START_TLS":
+			return boolValue(ldap.StartTLS), true
+		}
+	}
+
+	return "", false
+}
+
+func (auth *Auth) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if auth.Type == AuthAnonymous && auth.Guests {
+		errs = append(errs, field.Forbidden(path.Child("guests"), "every user is a guest of an anonymous instance"))
+	}
+
+	if jwt := auth.JWT; jwt != nil {
+		path := path.Child("jwt")
+		if auth.Type != AuthJWT {
+			errs = append(errs, field.Forbidden(path, "only used by the jwt type"))
+		}
+		if len(jwt.AppID) == 0 {
+			errs = append(errs, field.Required(path.Child("appId"), ""))
+		}
+		if jwt.AppSecret == nil && len(jwt.ASAPKeyServer) == 0 {
+			errs = append(errs, field.Required(path, "either appSecret or asapKeyServer is required"))
+		}
+	} else if auth.Type == AuthJWT {
+		errs = append(errs, field.Required(path.Child("jwt"), ""))
+	}
+
+	if ldap := auth.LDAP; ldap != nil {
+		path := path.Child("ldap")
+		if auth.Type != AuthLDAP {
+			errs = append(errs, field.Forbidden(path, "only used by the ldap type"))
+		}
+		if !strings.HasPrefix(ldap.URL, "ldap://") && !strings.HasPrefix(ldap.URL, "ldaps://") {
+			errs = append(errs, field.Invalid(path.Child("url"), ldap.URL, "must be an ldap:// or ldaps:// URL"))
+		}
+	} else if auth.Type == AuthLDAP {
+		errs = append(errs, field.Required(path.Child("ldap"), ""))
+	}
+
+	return errs
+}
+
+// secretSet tells whether the secret variable is referenced by auth
+func (auth *Auth) secretSet(name string) bool {
+	switch name {
+	case "JWT_APP_SECRET":
+		return auth.Type == AuthJWT && auth.JWT != nil && auth.JWT.AppSecret != nil
+	case "LDAP_BINDDN", "LDAP_BINDPW":
+		return auth.Type == AuthLDAP && auth.LDAP != nil && auth.LDAP.BindSecret != nil
+	}
+	return false
+}
+
+// DeprecatedSecretVariables returns the secret variables of spec.variables
+// spec.auth does not reference, passed to Prosody as they used to be
+func (jitsi *Jitsi) DeprecatedSecretVariables() map[string]string {
+	variables := map[string]string{}
+	for _, secret := range secretVariables {
+		value, set := jitsi.Spec.Variables[secret[0]]
+		if set && (jitsi.Spec.Auth == nil || !jitsi.Spec.Auth.secretSet(secret[0])) {
+			variables[secret[0]] = value
+		}
+	}
+	return variables
+}
+
+func (jitsi *Jitsi) authWarnings() []string {
+	warnings := []string{}
+
+	deprecated := jitsi.DeprecatedSecretVariables()
+	for _, secret := range secretVariables {
+		if _, set := jitsi.Spec.Variables[secret[0]]; !set {
+			continue
+		}
+		if _, ok := deprecated[secret[0]]; ok {
+			warnings = append(warnings, "spec.variables."+secret[0]+" is deprecated, it is passed to Prosody in a plain value, set it through "+secret[1])
+		} else {
+			warnings = append(warnings, "spec.variables."+secret[0]+" is overridden by "+secret[1])
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,57 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var defaultAutoscalerTargets = map[JVBAutoscalerMetric]resource.Quantity{
+	JVBMetricStress:       resource.MustParse("800m"),
+	JVBMetricParticipants: resource.MustParse("100"),
+	JVBMetricBitrate:      resource.MustParse("500000"),
+}
+
+// AutoscalerSettings returns the autoscaler of the strategy with its unset
+// fields defaulted
+func (strategy JVBStrategy) AutoscalerSettings() JVBAutoscaler {
+	autoscaler := JVBAutoscaler{}
+	if strategy.Autoscaler != nil {
+		autoscaler = *strategy.Autoscaler
+	}
+
+	if len(autoscaler.Backend) == 0 {
+		autoscaler.Backend = JVBAutoscalerMetricsAdapter
+	}
+
+	if len(autoscaler.Metric) == 0 {
+		autoscaler.Metric = JVBMetricStress
+	}
+
+	if autoscaler.Target == nil {
+		target := defaultAutoscalerTargets[autoscaler.Metric]
+		autoscaler.Target = &target
+	}
+
+	return autoscaler
+}
+
+// OperatorAutoscaled reports whether the operator scales the bridges itself
+func (strategy JVBStrategy) OperatorAutoscaled() bool {
+	return strategy.Type == JVBStrategyAutoScaled && strategy.AutoscalerSettings().Backend == JVBAutoscalerOperator
+}
+
+func (autoscaler *JVBAutoscaler) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if autoscaler.Target != nil && autoscaler.Target.Sign() <= 0 {
+		errs = append(errs, field.Invalid(path.Child("target"), autoscaler.Target.String(), "must be positive"))
+	}
+	if autoscaler.Backend == JVBAutoscalerKEDA && len(autoscaler.PrometheusServer) == 0 {
+		errs = append(errs, field.Required(path.Child("prometheusServer"), "required by the keda backend"))
+	}
+	if autoscaler.Backend != JVBAutoscalerKEDA && len(autoscaler.Query) > 0 {
+		errs = append(errs, field.Forbidden(path.Child("query"), "only used by the keda backend"))
+	}
+
+	return errs
+}
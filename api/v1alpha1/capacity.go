@@ -0,0 +1,86 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+)
+
+// Active reports whether the schedule applies at now, including its lead
+func (s *CapacitySchedule) Active(now time.Time) (bool, error) {
+	active, err := s.TimeWindow.Active(now)
+	if err != nil || active || s.Lead == nil {
+		return active, err
+	}
+	return s.TimeWindow.Active(now.Add(s.Lead.Duration))
+}
+
+// Validate checks the window and the replicas of the schedule
+func (s *CapacitySchedule) Validate() error {
+	if err := s.TimeWindow.Validate(); err != nil {
+		return err
+	}
+	if s.Lead != nil && s.Lead.Duration < 0 {
+		return fmt.Errorf("lead must not be negative")
+	}
+	for name, replicas := range map[string]*int32{"jvb": s.JVB, "web": s.Web, "jibri": s.Jibri} {
+		if replicas != nil && *replicas < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// ActiveCapacitySchedule returns the first capacity schedule that applies at
+// now, evaluated in spec.timezone
+func (jitsi *Jitsi) ActiveCapacitySchedule(now time.Time) (*CapacitySchedule, error) {
+	now = now.In(jitsi.Location())
+	for i := range jitsi.Spec.CapacitySchedules {
+		active, err := jitsi.Spec.CapacitySchedules[i].Active(now)
+		if err != nil {
+			return nil, fmt.Errorf("capacity schedule %s: %w", jitsi.Spec.CapacitySchedules[i].Name, err)
+		}
+		if active {
+			return &jitsi.Spec.CapacitySchedules[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func raiseReplicas(replicas **int32, floor *int32) {
+	if floor == nil {
+		return
+	}
+	if *replicas == nil || **replicas < *floor {
+		raised := *floor
+		*replicas = &raised
+	}
+}
+
+func (strategy *JVBStrategy) raiseReplicas(floor *int32) {
+	raiseReplicas(&strategy.Replicas, floor)
+	if floor != nil && strategy.MaxReplicas < *floor {
+		strategy.MaxReplicas = *floor
+	}
+}
+
+// ApplyCapacitySchedule raises the replicas of the defaulted spec to the
+// floors of the schedule
+func (jitsi *Jitsi) ApplyCapacitySchedule(schedule *CapacitySchedule) {
+	if schedule == nil {
+		return
+	}
+
+	jitsi.Spec.JVB.Strategy.raiseReplicas(schedule.JVB)
+	if jitsi.Sharded() {
+		for i := range jitsi.Spec.Shards.List {
+			if strategy := jitsi.Spec.Shards.List[i].JVBStrategy; strategy != nil {
+				strategy.raiseReplicas(schedule.JVB)
+			}
+		}
+	}
+
+	raiseReplicas(&jitsi.Spec.Web.Replicas, schedule.Web)
+	if jitsi.Spec.Jibri.Enabled {
+		raiseReplicas(&jitsi.Spec.Jibri.Replicas, schedule.Jibri)
+	}
+}
@@ -1,5 +1,5 @@
 // Code generated by go generate; DO NOT EDIT.
-package controllers
+package v1alpha1
 
 var JibriVariables = []string{
 	"AUTOSCALER_SIDECAR_KEY_FILE",
@@ -220,8 +220,6 @@ var ProsodyVariables = []string{
 	"LOG_LEVEL",
 	"LDAP_AUTH_METHOD",
 	"LDAP_BASE",
-	"LDAP_BINDDN",
-	"LDAP_BINDPW",
 	"LDAP_FILTER",
 	"LDAP_VERSION",
 	"LDAP_TLS_CIPHERS",
@@ -286,7 +284,6 @@ var ProsodyVariables = []string{
 	"XMPP_PORT",
 	"XMPP_SERVER_S2S_PORT",
 	"XMPP_SPEAKERSTATS_MODULES",
-	"JWT_APP_SECRET",
 }
 
 var WebVariables = []string{
@@ -0,0 +1,70 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// BridgeLabel holds the name of a bridge pod exposed by its own Service, the
+// Service selects the pod with it
+const BridgeLabel = "apps.jit.si/bridge"
+
+// ServiceExposed reports whether each bridge is exposed by its own Service
+// rather than a host port
+func (jvb *JVB) ServiceExposed() bool {
+	return jvb.Exposure != nil && len(jvb.Exposure.Type) > 0 && jvb.Exposure.Type != JVBExposureHostPort
+}
+
+// PortRange returns the first and last UDP ports allocated to the bridges
+func (jvb *JVB) PortRange() (int32, int32) {
+	size := int32(100)
+	if jvb.Exposure != nil && jvb.Exposure.PortRange != nil {
+		size = *jvb.Exposure.PortRange
+	}
+	return *jvb.Ports.UDP, *jvb.Ports.UDP + size - 1
+}
+
+func (jvb *JVB) validateExposure(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if !jvb.ServiceExposed() || jvb.Ports.UDP == nil {
+		return errs
+	}
+
+	if size := jvb.Exposure.PortRange; size != nil && *size < 1 {
+		errs = append(errs, field.Invalid(path.Child("portRange"), *size, "must be positive"))
+	} else if _, last := jvb.PortRange(); last > 65535 {
+		errs = append(errs, field.Invalid(path.Child("portRange"), last, "the last port must not exceed 65535"))
+	} else if jvb.TCP != nil && jvb.Ports.TCP != nil && jvb.TCPPort(last) > 65535 {
+		errs = append(errs, field.Invalid(path.Child("portRange"), jvb.TCPPort(last), "the last TCP port must not exceed 65535"))
+	}
+
+	return errs
+}
+
+// exposureWarnings warns about ports out of the default node port range,
+// which the NodePort Services of the bridges would not get
+func (jvb *JVB) exposureWarnings(path *field.Path) []string {
+	if !jvb.ServiceExposed() || jvb.Exposure.Type != JVBExposureNodePort || jvb.Ports.UDP == nil {
+		return nil
+	}
+
+	warnings := []string{}
+	first, last := jvb.PortRange()
+	if first < 30000 || last > 32767 {
+		warnings = append(warnings, path.String()+" allocates ports out of the default node port range 30000-32767, set ports.udp within the node port range of the cluster")
+	}
+	if jvb.TCP != nil && jvb.Ports.TCP != nil {
+		if jvb.TCPPort(first) < 30000 || jvb.TCPPort(last) > 32767 {
+			warnings = append(warnings, path.String()+" allocates TCP ports out of the default node port range 30000-32767, set ports.tcp within the node port range of the cluster")
+		}
+		if jvb.TCP.AdvertisedPort != nil {
+			warnings = append(warnings, path.String()+" publishes each bridge on its own TCP port, while tcp.advertisedPort is advertised by every bridge")
+		}
+	}
+	return warnings
+}
+
+// TCPPort returns the TCP port of the bridge listening on the UDP port
+// allocated from the range, both at the same offset of their range
+func (jvb *JVB) TCPPort(udp int32) int32 {
+	return *jvb.Ports.TCP + udp - *jvb.Ports.UDP
+}
@@ -0,0 +1,45 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegionGroupsValue renders the region groups in the format expected by
+// JICOFO_BRIDGE_REGION_GROUPS, e.g. ["eu-west","eu-central"],["us-east"]
+func (federation *Federation) RegionGroupsValue() string {
+	groups := make([]string, 0, len(federation.RegionGroups))
+	for _, group := range federation.RegionGroups {
+		regions := make([]string, 0, len(group.Regions))
+		for _, region := range group.Regions {
+			regions = append(regions, fmt.Sprintf("%q", region))
+		}
+		groups = append(groups, "["+strings.Join(regions, ",")+"]")
+	}
+	return strings.Join(groups, ",")
+}
+
+// Upstream returns the upstream the bridges join, nil if there is none
+func (jitsi *Jitsi) Upstream() *Upstream {
+	if jitsi.Spec.Federation == nil {
+		return nil
+	}
+	return jitsi.Spec.Federation.Upstream
+}
+
+// AllowsDownstream reports whether the instances of namespace may reference
+// jitsi as their upstream
+func (jitsi *Jitsi) AllowsDownstream(namespace string) bool {
+	if namespace == jitsi.Namespace {
+		return true
+	}
+	if jitsi.Spec.Federation == nil {
+		return false
+	}
+	for _, allowed := range jitsi.Spec.Federation.AllowedNamespaces {
+		if allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
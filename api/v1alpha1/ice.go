@@ -0,0 +1,59 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ServerPort returns the port of the server, defaulted from its protocol
+func (server *ICEServer) ServerPort() int32 {
+	if server.Port != 0 {
+		return server.Port
+	}
+	if server.Protocol == ICEProtocolTLS {
+		return 5349
+	}
+	return TURNPort
+}
+
+// STUNServers returns the host:port of the STUN servers of spec.iceServers
+func (jitsi *Jitsi) STUNServers() []string {
+	servers := []string{}
+	for i := range jitsi.Spec.ICEServers {
+		if server := &jitsi.Spec.ICEServers[i]; server.Type == ICEServerSTUN {
+			servers = append(servers, fmt.Sprintf("%s:%d", server.Host, server.ServerPort()))
+		}
+	}
+	return servers
+}
+
+func (server *ICEServer) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if len(server.Host) == 0 {
+		errs = append(errs, field.Required(path.Child("host"), ""))
+	}
+	if server.Port < 0 || server.Port > 65535 {
+		errs = append(errs, field.Invalid(path.Child("port"), server.Port, "must be between 1 and 65535"))
+	}
+
+	if server.Type == ICEServerSTUN {
+		if len(server.Protocol) > 0 && server.Protocol != ICEProtocolUDP {
+			errs = append(errs, field.Invalid(path.Child("protocol"), server.Protocol, "STUN servers are queried over udp"))
+		}
+		if server.Secret != nil || len(server.Username) > 0 || server.Password != nil {
+			errs = append(errs, field.Forbidden(path, "STUN servers take no credentials"))
+		}
+		return errs
+	}
+
+	if server.Secret != nil && (len(server.Username) > 0 || server.Password != nil) {
+		errs = append(errs, field.Forbidden(path.Child("secret"), "time limited credentials exclude username and password"))
+	}
+	if (len(server.Username) > 0) != (server.Password != nil) {
+		errs = append(errs, field.Required(path.Child("password"), "username and password go together"))
+	}
+
+	return errs
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
@@ -52,37 +54,88 @@ var defaultEnvVarMap = map[string]string{
 	// "ENABLE_HSTS":                    "0",
 }
 
+// EnvVarValue returns the value of the variable shared by the components
 func (jitsi *Jitsi) EnvVarValue(name string) string {
+	return jitsi.envVarValue(name, jitsi.Spec.Variables)
+}
+
+// ComponentEnvVarValue returns the value of the variable for component
+func (jitsi *Jitsi) ComponentEnvVarValue(component *ComponentEnv, name string) string {
+	return jitsi.envVarValue(name, jitsi.componentVariables(component))
+}
+
+// componentVariables returns the variables of component over spec.variables
+func (jitsi *Jitsi) componentVariables(component *ComponentEnv) map[string]string {
+	if len(component.Variables) == 0 {
+		return jitsi.Spec.Variables
+	}
+
+	variables := map[string]string{}
+	for name, value := range jitsi.Spec.Variables {
+		variables[name] = value
+	}
+	for name, value := range component.Variables {
+		variables[name] = value
+	}
+	return variables
+}
+
+// envVarValue resolves the variable from the settings of the spec, then the
+// variables, then the defaults
+func (jitsi *Jitsi) envVarValue(name string, variables map[string]string) string {
 	var value string
 
+	if jitsi.Spec.Auth != nil {
+		if value, ok := jitsi.Spec.Auth.envVarValue(name); ok {
+			return value
+		}
+	}
+	if jitsi.Spec.Prosody.Config != nil {
+		if value, ok := jitsi.Spec.Prosody.Config.envVarValue(name); ok {
+			return value
+		}
+	}
+
 	switch name {
 	case "TZ":
 		value = jitsi.Spec.Timezone
-	case "XMPP_SERVER":
-		value = fmt.Sprintf("%s-prosody", jitsi.Name)
-	case "XMPP_BOSH_URL_BASE":
-		value = fmt.Sprintf("http://%s.%s.svc.cluster.local:5280", jitsi.EnvVarValue("XMPP_SERVER"), jitsi.Namespace)
+	case "XMPP_SERVER", "XMPP_BOSH_URL_BASE":
+		value = jitsi.ShardEnvVarValue(&Shard{}, name)
 	case "JVB_PORT":
 		value = strconv.FormatInt(int64(*jitsi.Spec.JVB.Ports.UDP), 10)
 	case "JVB_TCP_PORT":
 		value = strconv.FormatInt(int64(*jitsi.Spec.JVB.Ports.TCP), 10)
+	case "JVB_STUN_SERVERS":
+		if variables[name] != "" {
+			value = variables[name]
+		} else if stun := jitsi.STUNServers(); len(stun) > 0 {
+			value = strings.Join(stun, ",")
+		} else {
+			value = defaultEnvVarMap[name]
+		}
 	case "DEPLOYMENTINFO_USERREGION":
 		value = jitsi.Spec.Region
-	case "JVB_OCTO_REGION":
+	case "JVB_OCTO_REGION", "JICOFO_OCTO_REGION":
 		value = jitsi.Spec.Region
+	case "JICOFO_BRIDGE_REGION_GROUPS":
+		if jitsi.Spec.Federation != nil && len(jitsi.Spec.Federation.RegionGroups) > 0 {
+			value = jitsi.Spec.Federation.RegionGroupsValue()
+		} else {
+			value = variables[name]
+		}
 	case "DEPLOYMENTINFO_REGION":
 		value = jitsi.Spec.Region
 	case "PUBLIC_URL":
 		value = "https://" + jitsi.Spec.Domain
 	case "SHUTDOWN_REST_ENABLED":
-		if jitsi.Spec.JVB.GracefulShutdown || jitsi.Spec.Variables["SHUTDOWN_REST_ENABLED"] == "1" {
+		if jitsi.Spec.JVB.GracefulShutdown || variables["SHUTDOWN_REST_ENABLED"] == "1" {
 			value = "1"
 		} else {
 			value = "0"
 		}
 	default:
-		if jitsi.Spec.Variables[name] != "" {
-			value = jitsi.Spec.Variables[name]
+		if variables[name] != "" {
+			value = variables[name]
 		} else {
 			value = defaultEnvVarMap[name]
 		}
@@ -109,7 +162,11 @@ func (jitsi *Jitsi) EnvVars(names []string) []corev1.EnvVar {
 	return envVars
 }
 
+// SetDefaults fills in every unset field of the spec, including the component
+// images which follow the operator version
 func (jitsi *Jitsi) SetDefaults() {
+	jitsi.SetSpecDefaults()
+
 	if len(jitsi.Spec.Image.Registry) == 0 {
 		jitsi.Spec.Image.Registry = "ghcr.io/enna-systems/jitsi-kubernetes-operator"
 	}
@@ -126,25 +183,6 @@ func (jitsi *Jitsi) SetDefaults() {
 		}
 	}
 
-	if jitsi.Spec.JVB.Strategy.Replicas == nil {
-		defaultReplicas := int32(1)
-		jitsi.Spec.JVB.Strategy.Replicas = &defaultReplicas
-	}
-
-	if len(jitsi.Spec.JVB.Strategy.Type) == 0 {
-		jitsi.Spec.JVB.Strategy.Type = JVBStrategyStatic
-	}
-
-	if jitsi.Spec.JVB.Ports.TCP == nil {
-		defaultPort := int32(30301)
-		jitsi.Spec.JVB.Ports.TCP = &defaultPort
-	}
-
-	if jitsi.Spec.JVB.Ports.UDP == nil {
-		defaultPort := int32(10000)
-		jitsi.Spec.JVB.Ports.UDP = &defaultPort
-	}
-
 	if jitsi.Spec.JVB.ContainerRuntime == nil {
 		jitsi.Spec.JVB.ContainerRuntime = &ContainerRuntime{}
 	}
@@ -157,20 +195,15 @@ func (jitsi *Jitsi) SetDefaults() {
 		jitsi.Spec.JVB.ImagePullPolicy = jitsi.Spec.Image.PullPolicy
 	}
 
+	if jitsi.Spec.JVB.PublicIP != nil {
+		jitsi.Spec.JVB.PublicIP.setDefaults()
+	}
+
 	if jitsi.Spec.Jibri.ContainerRuntime == nil {
 		jitsi.Spec.Jibri.ContainerRuntime = &ContainerRuntime{}
 	}
 
 	if jitsi.Spec.Jibri.Enabled {
-		if jitsi.Spec.Jibri.Replicas == nil {
-			defaultReplicas := int32(1)
-			jitsi.Spec.Jibri.Replicas = &defaultReplicas
-		}
-
-		if jitsi.Spec.Jibri.ContainerRuntime == nil {
-			jitsi.Spec.Jibri.ContainerRuntime = &ContainerRuntime{}
-		}
-
 		if len(jitsi.Spec.Jibri.Image) == 0 {
 			jitsi.Spec.Jibri.Image = fmt.Sprintf("%s/jibri:%s", jitsi.Spec.Image.Registry, jitsi.Spec.Image.Tag)
 		}
@@ -204,11 +237,6 @@ func (jitsi *Jitsi) SetDefaults() {
 		jitsi.Spec.Jicofo.ImagePullPolicy = jitsi.Spec.Image.PullPolicy
 	}
 
-	if jitsi.Spec.Web.Replicas == nil {
-		defaultReplicas := int32(1)
-		jitsi.Spec.Web.Replicas = &defaultReplicas
-	}
-
 	if jitsi.Spec.Web.ContainerRuntime == nil {
 		jitsi.Spec.Web.ContainerRuntime = &ContainerRuntime{}
 	}
@@ -221,9 +249,97 @@ func (jitsi *Jitsi) SetDefaults() {
 		jitsi.Spec.Web.ImagePullPolicy = jitsi.Spec.Image.PullPolicy
 	}
 
+	if turn := jitsi.ManagedTURN(); turn != nil {
+		if turn.ContainerRuntime == nil {
+			turn.ContainerRuntime = &ContainerRuntime{}
+		}
+
+		if len(turn.Image) == 0 {
+			turn.Image = "docker.io/coturn/coturn:4.6"
+		}
+
+		if len(turn.ImagePullPolicy) == 0 {
+			turn.ImagePullPolicy = corev1.PullIfNotPresent
+		}
+	}
+
 	if jitsi.Spec.Ingress.Annotations == nil {
 		jitsi.Spec.Ingress.Annotations = make(map[string]string)
 	}
+
+	if jitsi.Sharded() {
+		router := &jitsi.Spec.Shards.Router
+		if router.ContainerRuntime == nil {
+			router.ContainerRuntime = &ContainerRuntime{}
+		}
+
+		if len(router.Image) == 0 {
+			router.Image = "docker.io/library/haproxy:2.8"
+		}
+
+		if len(router.ImagePullPolicy) == 0 {
+			router.ImagePullPolicy = corev1.PullIfNotPresent
+		}
+	}
+}
+
+// SetSpecDefaults fills in the defaults which do not depend on the operator
+// version. They are persisted by the defaulting webhook, while images are
+// left unset so that they keep following operator upgrades.
+func (jitsi *Jitsi) SetSpecDefaults() {
+	if jitsi.Spec.JVB.Strategy.Replicas == nil {
+		defaultReplicas := int32(1)
+		jitsi.Spec.JVB.Strategy.Replicas = &defaultReplicas
+	}
+
+	if len(jitsi.Spec.JVB.Strategy.Type) == 0 {
+		jitsi.Spec.JVB.Strategy.Type = JVBStrategyStatic
+	}
+
+	if jitsi.Spec.JVB.Ports.TCP == nil {
+		defaultPort := int32(30301)
+		jitsi.Spec.JVB.Ports.TCP = &defaultPort
+	}
+
+	if jitsi.Spec.JVB.Ports.UDP == nil {
+		defaultPort := int32(10000)
+		jitsi.Spec.JVB.Ports.UDP = &defaultPort
+	}
+
+	if jitsi.Spec.Jibri.Enabled && jitsi.Spec.Jibri.Replicas == nil {
+		defaultReplicas := int32(1)
+		jitsi.Spec.Jibri.Replicas = &defaultReplicas
+	}
+
+	if jitsi.Spec.Web.Replicas == nil {
+		defaultReplicas := int32(1)
+		jitsi.Spec.Web.Replicas = &defaultReplicas
+	}
+
+	if jitsi.Spec.TURN != nil {
+		jitsi.Spec.TURN.setDefaults()
+	}
+
+	if jitsi.Sharded() {
+		if len(jitsi.Spec.Shards.List) == 0 && jitsi.Spec.Shards.Count == 0 {
+			jitsi.Spec.Shards.Count = 1
+		}
+
+		if jitsi.Spec.Shards.Router.Replicas == nil {
+			defaultReplicas := int32(1)
+			jitsi.Spec.Shards.Router.Replicas = &defaultReplicas
+		}
+	}
+}
+
+func (jitsi *Jitsi) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&jitsi.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: jitsi.Generation,
+	})
 }
 
 func (jitsi *Jitsi) ComponentLabels(component string) labels.Set {
@@ -244,37 +360,37 @@ func (jitsi *Jitsi) Labels() labels.Set {
 	return labels
 }
 
-func (jitsi *Jitsi) JibriDeployment() appsv1.Deployment {
+func (jitsi *Jitsi) JibriDeployment(shard *Shard) appsv1.Deployment {
 	return appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-jibri", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "jibri"),
 			Namespace: jitsi.Namespace,
 		},
 	}
 }
 
-func (jitsi *Jitsi) JVBHPA() autoscalingv2.HorizontalPodAutoscaler {
+func (jitsi *Jitsi) JVBHPA(shard *Shard) autoscalingv2.HorizontalPodAutoscaler {
 	return autoscalingv2.HorizontalPodAutoscaler{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-jvb", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "jvb"),
 			Namespace: jitsi.Namespace,
 		},
 	}
 }
 
-func (jitsi *Jitsi) JVBDeployment() appsv1.Deployment {
+func (jitsi *Jitsi) JVBDeployment(shard *Shard) appsv1.Deployment {
 	return appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-jvb", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "jvb"),
 			Namespace: jitsi.Namespace,
 		},
 	}
 }
 
-func (jitsi *Jitsi) JVBDaemonSet() appsv1.DaemonSet {
+func (jitsi *Jitsi) JVBDaemonSet(shard *Shard) appsv1.DaemonSet {
 	return appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-jvb", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "jvb"),
 			Namespace: jitsi.Namespace,
 		},
 	}
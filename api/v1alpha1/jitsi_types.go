@@ -17,7 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -39,6 +41,63 @@ type JVBStrategy struct {
 	Replicas *int32 `json:"replicas,omitempty"`
 	//+optional
 	MaxReplicas int32 `json:"maxReplicas,omitempty"`
+	// Autoscaler configures how the autoscaled strategy scales the bridges
+	//+optional
+	Autoscaler *JVBAutoscaler `json:"autoscaler,omitempty"`
+}
+
+type JVBAutoscalerBackend string
+
+const (
+	// JVBAutoscalerMetricsAdapter scales on the colibri stats of the bridges
+	// read by the zalando kube-metrics-adapter
+	JVBAutoscalerMetricsAdapter JVBAutoscalerBackend = "metrics-adapter"
+	// JVBAutoscalerPrometheusAdapter scales on external metrics served by the
+	// Prometheus Adapter
+	JVBAutoscalerPrometheusAdapter JVBAutoscalerBackend = "prometheus-adapter"
+	// JVBAutoscalerKEDA scales through a KEDA ScaledObject querying Prometheus
+	JVBAutoscalerKEDA JVBAutoscalerBackend = "keda"
+	// JVBAutoscalerOperator scales from the operator on the colibri stats of
+	// the bridges, draining the least loaded bridge before removing it
+	JVBAutoscalerOperator JVBAutoscalerBackend = "operator"
+)
+
+type JVBAutoscalerMetric string
+
+const (
+	JVBMetricStress       JVBAutoscalerMetric = "stress"
+	JVBMetricParticipants JVBAutoscalerMetric = "participants"
+	JVBMetricBitrate      JVBAutoscalerMetric = "bitrate"
+)
+
+type JVBAutoscaler struct {
+	// Backend scaling the bridges, metrics-adapter by default
+	//+kubebuilder:validation:Enum=metrics-adapter;prometheus-adapter;keda;operator
+	//+optional
+	Backend JVBAutoscalerBackend `json:"backend,omitempty"`
+	// Metric of the bridges to scale on, stress by default
+	//+kubebuilder:validation:Enum=stress;participants;bitrate
+	//+optional
+	Metric JVBAutoscalerMetric `json:"metric,omitempty"`
+	// Target average of the metric per bridge, 800m stress, 100 participants
+	// or 500000 kbps of outgoing bitrate by default
+	//+optional
+	Target *resource.Quantity `json:"target,omitempty"`
+	// MetricName overrides the Prometheus metric of the prometheus-adapter
+	// and keda backends
+	//+optional
+	MetricName string `json:"metricName,omitempty"`
+	// PrometheusServer is the address of the Prometheus queried by KEDA
+	//+optional
+	PrometheusServer string `json:"prometheusServer,omitempty"`
+	// Query overrides the PromQL query of KEDA, it must return the sum of
+	// the metric over the bridges
+	//+optional
+	Query string `json:"query,omitempty"`
+	// Behavior configures the scaling speed of the bridges, the operator
+	// backend scales down one bridge at a time instead
+	//+optional
+	Behavior *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
 }
 
 type JVBPorts struct {
@@ -51,19 +110,193 @@ type JVBPorts struct {
 type JVB struct {
 	*ContainerRuntime `json:",inline"`
 	AffinitySettings  `json:",inline"`
+	PodOverrides      `json:",inline"`
+	ComponentEnv      `json:",inline"`
 	//+optional
 	Strategy JVBStrategy `json:"strategy,omitempty"`
 	//+optional
 	Ports JVBPorts `json:"ports,omitempty"`
 	//+optional
 	GracefulShutdown bool `json:"gracefulShutdown,omitempty"`
+	// PublicIP makes each bridge advertise the public address of its node
+	//+optional
+	PublicIP *PublicIP `json:"publicIP,omitempty"`
+	// Exposure tells how the media port of the bridges is reachable,
+	// through a host port by default
+	//+optional
+	Exposure *JVBExposure `json:"exposure,omitempty"`
+	// TCP lets clients behind firewalls blocking UDP reach the bridges over
+	// TCP on ports.tcp
+	//+optional
+	TCP *JVBTCP `json:"tcp,omitempty"`
+}
+
+// JVBTCP enables the TCP fallback of the media of the bridges
+type JVBTCP struct {
+	// AdvertisedPort is the port the clients reach when a load balancer or
+	// a NAT maps it to ports.tcp, e.g. 443
+	//+optional
+	AdvertisedPort *int32 `json:"advertisedPort,omitempty"`
+	// SSLTCP disguises the TCP candidates as TLS, true by default
+	//+optional
+	SSLTCP *bool `json:"ssltcp,omitempty"`
+}
+
+type JVBExposureType string
+
+const (
+	// JVBExposureHostPort binds the UDP port on the node, one bridge per node
+	JVBExposureHostPort JVBExposureType = "HostPort"
+	// JVBExposureNodePort gives each bridge a NodePort Service on its own
+	// UDP port
+	JVBExposureNodePort JVBExposureType = "NodePort"
+	// JVBExposureLoadBalancer gives each bridge a LoadBalancer Service on
+	// its own UDP port, the bridge advertises the address of its load
+	// balancer
+	JVBExposureLoadBalancer JVBExposureType = "LoadBalancer"
+)
+
+// JVBExposure exposes the bridges through a Service each rather than a host
+// port, so that several bridges share a node
+type JVBExposure struct {
+	//+kubebuilder:validation:Enum=HostPort;NodePort;LoadBalancer
+	//+optional
+	Type JVBExposureType `json:"type,omitempty"`
+	// PortRange is the number of UDP ports from ports.udp allocated to the
+	// bridges, 100 by default. NodePort Services need them in the node port
+	// range of the cluster.
+	//+optional
+	PortRange *int32 `json:"portRange,omitempty"`
+	// Annotations of the Service of each bridge
+	//+optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type PublicIPSource string
+
+const (
+	// PublicIPFromExternalIP advertises the ExternalIP addresses of the node
+	PublicIPFromExternalIP PublicIPSource = "ExternalIP"
+	// PublicIPFromLabel advertises the addresses held by a label of the node
+	PublicIPFromLabel PublicIPSource = "Label"
+	// PublicIPFromAnnotation advertises the addresses held by an annotation
+	// of the node
+	PublicIPFromAnnotation PublicIPSource = "Annotation"
+	// PublicIPFromMetadata advertises the address returned by a metadata
+	// endpoint, queried by an init container on the node
+	PublicIPFromMetadata PublicIPSource = "Metadata"
+)
+
+// PublicIP tells where the bridges find the public address of their node,
+// so that they advertise it without asking STUN servers
+type PublicIP struct {
+	// Source of the address, ExternalIP by default
+	//+kubebuilder:validation:Enum=ExternalIP;Label;Annotation;Metadata
+	//+optional
+	Source PublicIPSource `json:"source,omitempty"`
+	// Key of the label or annotation of the nodes holding their comma
+	// separated addresses
+	//+optional
+	Key string `json:"key,omitempty"`
+	// MetadataURL returns the public address of the node as plain text,
+	// e.g. http://169.254.169.254/latest/meta-data/public-ipv4
+	//+optional
+	MetadataURL string `json:"metadataURL,omitempty"`
+	// MetadataHeaders are sent along the metadata request, e.g.
+	// Metadata-Flavor: Google
+	//+optional
+	MetadataHeaders map[string]string `json:"metadataHeaders,omitempty"`
+	// MetadataImage runs the init container querying MetadataURL, it
+	// requires wget
+	//+optional
+	MetadataImage string `json:"metadataImage,omitempty"`
+	// KeepSTUN keeps discovering the address through the STUN servers too
+	//+optional
+	KeepSTUN bool `json:"keepSTUN,omitempty"`
 }
 
 type Prosody struct {
 	*ContainerRuntime `json:",inline"`
 	AffinitySettings  `json:",inline"`
+	PodOverrides      `json:",inline"`
+	ComponentEnv      `json:",inline"`
+	// CustomProsodyConfig replaces the whole jitsi-meet.cfg.lua, config and
+	// snippets are to be preferred
 	//+optional
 	CustomProsodyConfig *corev1.LocalObjectReference `json:"customProsodyConfigCM,omitempty"`
+	// Config sets the common settings of Prosody through its variables
+	//+optional
+	Config *ProsodyConfig `json:"config,omitempty"`
+	// Plugins are extra Lua modules of Prosody, enabled through config
+	//+optional
+	Plugins []ProsodyPlugin `json:"plugins,omitempty"`
+	// Snippets are Lua configuration files included after
+	// jitsi-meet.cfg.lua
+	//+optional
+	Snippets []ProsodySnippet `json:"snippets,omitempty"`
+}
+
+// ProsodyConfig are the common settings of Prosody, unset ones are left to
+// the variables
+type ProsodyConfig struct {
+	// Modules are enabled on the virtual host of the users, on top of
+	// those of the image
+	//+optional
+	Modules []string `json:"modules,omitempty"`
+	// MUCModules are enabled on the conference MUC
+	//+optional
+	MUCModules []string `json:"mucModules,omitempty"`
+	// GlobalModules are enabled on every host
+	//+optional
+	GlobalModules []string `json:"globalModules,omitempty"`
+	//+optional
+	Lobby *bool `json:"lobby,omitempty"`
+	//+optional
+	BreakoutRooms *bool `json:"breakoutRooms,omitempty"`
+	//+optional
+	Polls *bool `json:"polls,omitempty"`
+	// MaxParticipants limits the occupants of a room
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	MaxParticipants *int32 `json:"maxParticipants,omitempty"`
+	// RoomMetadata declares the room_metadata component, sharing the
+	// metadata of the rooms with their participants
+	//+optional
+	RoomMetadata bool `json:"roomMetadata,omitempty"`
+}
+
+// ProsodyPlugin is a Lua module of Prosody, its files are mounted into
+// /prosody-plugins-custom/mod_<name>
+type ProsodyPlugin struct {
+	// Name of the module, loaded from mod_<name>.lua
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([a-z0-9_]*[a-z0-9])?$`
+	//+kubebuilder:validation:MaxLength=50
+	Name string `json:"name"`
+	// ConfigMap holds mod_<name>.lua and the files it requires
+	//+optional
+	ConfigMap *corev1.LocalObjectReference `json:"configMap,omitempty"`
+	// Image holds the files of the module in path, they are copied by an
+	// init container running cp from the image, which must provide it
+	//+optional
+	Image string `json:"image,omitempty"`
+	// Path of the files of the module in the image, /plugins by default
+	//+optional
+	Path string `json:"path,omitempty"`
+}
+
+// ProsodySnippet is Lua configuration added to the one of the image. The
+// snippets are included after jitsi-meet.cfg.lua in their order, each starts
+// in the global section.
+type ProsodySnippet struct {
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	//+kubebuilder:validation:MaxLength=50
+	Name string `json:"name"`
+	// Config is the inline configuration
+	//+optional
+	Config string `json:"config,omitempty"`
+	// ConfigMap holds the configuration in one of its keys
+	//+optional
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
 }
 
 type ContainerRuntime struct {
@@ -82,9 +315,90 @@ type AffinitySettings struct {
 	DisableDefaultAffinity bool `json:"disableDefaultAffinity,omitempty"`
 }
 
+// PodOverrides customize the pods of a component beyond what the operator
+// sets up, they are applied last
+type PodOverrides struct {
+	// PodLabels are added to the labels of the pods, those of the operator
+	// take precedence
+	//+optional
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+	// PodAnnotations are added to the annotations of the pods, those of the
+	// operator take precedence
+	//+optional
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+	//+optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	//+optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	//+optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	//+optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	//+optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext replaces the one of the container of the component
+	//+optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	//+optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+	// ExtraVolumes, Sidecars and ExtraInitContainers are left out of the
+	// schema of the CRD, which would not fit in etcd with them for every
+	// component, they are checked when the pods are created
+	//+optional
+	//+kubebuilder:validation:Schemaless
+	//+kubebuilder:pruning:PreserveUnknownFields
+	ExtraVolumes []corev1.Volume `json:"extraVolumes,omitempty"`
+	// ExtraVolumeMounts are added to the container of the component
+	//+optional
+	ExtraVolumeMounts []corev1.VolumeMount `json:"extraVolumeMounts,omitempty"`
+	//+optional
+	//+kubebuilder:validation:Schemaless
+	//+kubebuilder:pruning:PreserveUnknownFields
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+	//+optional
+	//+kubebuilder:validation:Schemaless
+	//+kubebuilder:pruning:PreserveUnknownFields
+	ExtraInitContainers []corev1.Container `json:"extraInitContainers,omitempty"`
+	// Patches are applied in order to the pod template, for anything the
+	// fields above do not cover
+	//+optional
+	Patches []PodPatch `json:"patches,omitempty"`
+}
+
+// ComponentEnv configures the environment of a single component, over the
+// variables shared by all of them
+type ComponentEnv struct {
+	// Variables of the component take precedence over spec.variables, they
+	// may be variables the operator does not know of
+	//+optional
+	Variables map[string]string `json:"variables,omitempty"`
+	// Env is added to the container of the component last, replacing the
+	// variables of the same name whatever they are set by
+	//+optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+type PodPatchType string
+
+const (
+	PodPatchStrategicMerge PodPatchType = "StrategicMerge"
+	PodPatchJSON           PodPatchType = "JSON"
+)
+
+// PodPatch is a patch of the pod template of a component
+type PodPatch struct {
+	//+kubebuilder:validation:Enum=StrategicMerge;JSON
+	Type PodPatchType `json:"type"`
+	// Patch is the YAML or JSON of a strategic merge patch, or of the
+	// operations of a JSON patch
+	Patch string `json:"patch"`
+}
+
 type Jicofo struct {
 	*ContainerRuntime `json:",inline"`
 	AffinitySettings  `json:",inline"`
+	PodOverrides      `json:",inline"`
+	ComponentEnv      `json:",inline"`
 }
 
 type BucketSettings struct {
@@ -98,6 +412,8 @@ type BucketSettings struct {
 type Jibri struct {
 	*ContainerRuntime `json:",inline"`
 	AffinitySettings  `json:",inline"`
+	PodOverrides      `json:",inline"`
+	ComponentEnv      `json:",inline"`
 	//+optional
 	Enabled bool `json:"enabled,omitempty"`
 	//+optional
@@ -111,6 +427,8 @@ type Jibri struct {
 type Web struct {
 	*ContainerRuntime `json:",inline"`
 	AffinitySettings  `json:",inline"`
+	PodOverrides      `json:",inline"`
+	ComponentEnv      `json:",inline"`
 	//+optional
 	Replicas *int32 `json:"replicas,omitempty"`
 	//+optional
@@ -150,6 +468,375 @@ type TURN struct {
 	Port int `json:"port,omitempty"`
 	//+optional
 	TLS bool `json:"tls,omitempty"`
+	// Managed deploys coturn on host rather than pointing at an external
+	// TURN server. Port is then the TURNS port when TLS is set, 443 by
+	// default, while TURN listens on 3478.
+	//+optional
+	Managed *ManagedTURN `json:"managed,omitempty"`
+}
+
+type TURNMode string
+
+const (
+	// TURNHostNetwork runs coturn on the network of the selected nodes, the
+	// host resolves to their public addresses
+	TURNHostNetwork TURNMode = "HostNetwork"
+	// TURNLoadBalancer runs coturn behind a LoadBalancer Service, the host
+	// resolves to its address
+	TURNLoadBalancer TURNMode = "LoadBalancer"
+)
+
+// ManagedTURN is a coturn deployed by the operator, sharing a secret with
+// Prosody to authenticate the users
+type ManagedTURN struct {
+	*ContainerRuntime `json:",inline"`
+	// Mode is LoadBalancer by default
+	//+kubebuilder:validation:Enum=HostNetwork;LoadBalancer
+	//+optional
+	Mode TURNMode `json:"mode,omitempty"`
+	// Replicas of the LoadBalancer mode, 0 or 1 as the relay ports of the
+	// load balancer reach a single coturn, 1 by default
+	//+optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// NodeSelector picks the nodes running coturn in the HostNetwork mode
+	//+optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// PublicIP makes coturn of the HostNetwork mode relay on the public
+	// address of its node, when the node sits behind a NAT
+	//+optional
+	PublicIP *PublicIP `json:"publicIP,omitempty"`
+	//+optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Annotations of the Service of the LoadBalancer mode
+	//+optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// MinRelayPort and MaxRelayPort bound the UDP ports relaying the media,
+	// 49160-49200 by default. The LoadBalancer mode publishes at most 100 of
+	// them.
+	//+optional
+	MinRelayPort *int32 `json:"minRelayPort,omitempty"`
+	//+optional
+	MaxRelayPort *int32 `json:"maxRelayPort,omitempty"`
+	// TLSSecret is the kubernetes.io/tls Secret holding the certificate of
+	// host for TURNS, <host>-tls by default
+	//+optional
+	TLSSecret string `json:"tlsSecret,omitempty"`
+	// Issuer has cert-manager issue the certificate of host into TLSSecret
+	//+optional
+	Issuer *IssuerReference `json:"issuer,omitempty"`
+}
+
+type ICEServerType string
+
+const (
+	ICEServerSTUN ICEServerType = "stun"
+	ICEServerTURN ICEServerType = "turn"
+)
+
+type ICEProtocol string
+
+const (
+	ICEProtocolUDP ICEProtocol = "udp"
+	ICEProtocolTCP ICEProtocol = "tcp"
+	// ICEProtocolTLS is TURNS, TURN over TLS
+	ICEProtocolTLS ICEProtocol = "tls"
+)
+
+// ICEServer is a STUN or TURN server the clients use to reach the bridges
+type ICEServer struct {
+	//+kubebuilder:validation:Enum=stun;turn
+	Type ICEServerType `json:"type"`
+	Host string        `json:"host"`
+	// Port is 3478 by default, 5349 for TLS
+	//+optional
+	Port int32 `json:"port,omitempty"`
+	// Protocol of a TURN server, udp by default
+	//+kubebuilder:validation:Enum=udp;tcp;tls
+	//+optional
+	Protocol ICEProtocol `json:"protocol,omitempty"`
+	// Secret shared with a TURN server handing out time limited credentials
+	//+optional
+	Secret *corev1.SecretKeySelector `json:"secret,omitempty"`
+	// Username and Password are static credentials of a TURN server
+	//+optional
+	Username string `json:"username,omitempty"`
+	//+optional
+	Password *corev1.SecretKeySelector `json:"password,omitempty"`
+	// Priority orders the servers offered to the clients, highest first
+	//+optional
+	Priority int32 `json:"priority,omitempty"`
+}
+
+type AuthType string
+
+const (
+	// AuthAnonymous lets anyone create conferences
+	AuthAnonymous AuthType = "anonymous"
+	// AuthInternal authenticates the users registered in Prosody
+	AuthInternal AuthType = "internal"
+	// AuthJWT authenticates the users with a token issued by an application
+	AuthJWT AuthType = "jwt"
+	// AuthLDAP authenticates the users against an LDAP directory
+	AuthLDAP AuthType = "ldap"
+)
+
+// Auth controls who may create conferences
+type Auth struct {
+	//+kubebuilder:validation:Enum=anonymous;internal;jwt;ldap
+	Type AuthType `json:"type"`
+	// Guests join the conferences created by authenticated users without
+	// logging in
+	//+optional
+	Guests bool `json:"guests,omitempty"`
+	// GuestDomain is the XMPP domain of the guests, guest.meet.jitsi by
+	// default
+	//+optional
+	GuestDomain string `json:"guestDomain,omitempty"`
+	//+optional
+	JWT *JWTAuth `json:"jwt,omitempty"`
+	//+optional
+	LDAP *LDAPAuth `json:"ldap,omitempty"`
+}
+
+// JWTAuth verifies the tokens of an application, signed either with a shared
+// secret or with keys published by an ASAP key server
+type JWTAuth struct {
+	AppID string `json:"appId"`
+	// AppSecret is the secret shared with the application
+	//+optional
+	AppSecret *corev1.SecretKeySelector `json:"appSecret,omitempty"`
+	// AcceptedIssuers are the accepted iss claims, any by default
+	//+optional
+	AcceptedIssuers []string `json:"acceptedIssuers,omitempty"`
+	// AcceptedAudiences are the accepted aud claims, any by default
+	//+optional
+	AcceptedAudiences []string `json:"acceptedAudiences,omitempty"`
+	// ASAPKeyServer is the URL the public keys are fetched from
+	//+optional
+	ASAPKeyServer string `json:"asapKeyServer,omitempty"`
+	// AllowEmpty lets users without a token in
+	//+optional
+	AllowEmpty bool `json:"allowEmpty,omitempty"`
+	// TokenAuthURL is where the users without a token are sent to get one
+	//+optional
+	TokenAuthURL string `json:"tokenAuthUrl,omitempty"`
+}
+
+// LDAPAuth checks the credentials of the users against an LDAP directory
+type LDAPAuth struct {
+	// URL of the directory, e.g. ldaps://ldap.example.com
+	URL string `json:"url"`
+	// Base is the DN the users are searched under
+	//+optional
+	Base string `json:"base,omitempty"`
+	// BindSecret holds the DN searching the users in its dn key and its
+	// password in its password key, the search is anonymous without it
+	//+optional
+	BindSecret *corev1.LocalObjectReference `json:"bindSecret,omitempty"`
+	// Filter selects the users, e.g. (uid=%u)
+	//+optional
+	Filter string `json:"filter,omitempty"`
+	//+optional
+	StartTLS bool `json:"startTLS,omitempty"`
+}
+
+// Secrets sets where the passwords the components authenticate each other
+// with come from
+type Secrets struct {
+	// Name of an existing Secret of the namespace holding the passwords, e.g.
+	// synced by external-secrets, rather than the one generated by the
+	// operator. It is neither generated nor rotated by the operator, its
+	// changes are rolled out like rotations.
+	//+optional
+	Name string `json:"name,omitempty"`
+	// RotationSchedule is a cron expression, evaluated in spec.timezone, at
+	// which the generated passwords are regenerated
+	//+optional
+	RotationSchedule string `json:"rotationSchedule,omitempty"`
+}
+
+// IssuerReference references a cert-manager Issuer or ClusterIssuer
+type IssuerReference struct {
+	Name string `json:"name"`
+	// Kind is Issuer by default
+	//+kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	//+optional
+	Kind string `json:"kind,omitempty"`
+}
+
+// TimeWindow is a recurring period of time evaluated in spec.timezone. It is
+// either a cron schedule opening the window for a duration, or a time of day
+// range on a set of week days.
+type TimeWindow struct {
+	// Schedule is a cron expression opening the window, e.g. "0 2 * * *"
+	//+optional
+	Schedule string `json:"schedule,omitempty"`
+	// Duration is how long a window opened by Schedule lasts
+	//+optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Days the window applies to, every day when empty
+	//+optional
+	Days []Weekday `json:"days,omitempty"`
+	// Start is the time of day the window opens at, as HH:MM
+	//+optional
+	//+kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start,omitempty"`
+	// End is the time of day the window closes at, as HH:MM. A window
+	// ending before it starts spans midnight.
+	//+optional
+	//+kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Mon;Tue;Wed;Thu;Fri;Sat;Sun
+type Weekday string
+
+type UpgradeStrategy struct {
+	// MaintenanceWindows restrict when a pending upgrade may be rolled out
+	//+optional
+	MaintenanceWindows []TimeWindow `json:"maintenanceWindows,omitempty"`
+	// MaxWait is how long an upgrade waits for conferences to end before it is forced
+	//+optional
+	MaxWait *metav1.Duration `json:"maxWait,omitempty"`
+	// Drain upgrades the bridges first while the upgrade waits for conferences,
+	// so that new conferences only land on upgraded bridges. It requires
+	// jvb.gracefulShutdown for old bridges to finish their conferences.
+	//+optional
+	Drain bool `json:"drain,omitempty"`
+}
+
+// CapacitySchedule raises the replicas of the components while its window
+// is open, ahead of a predictable load
+type CapacitySchedule struct {
+	// Name of the schedule, reported in the status while it is active
+	//+required
+	Name       string `json:"name"`
+	TimeWindow `json:",inline"`
+	// Lead opens the window earlier so that the replicas are ready when the
+	// load comes
+	//+optional
+	Lead *metav1.Duration `json:"lead,omitempty"`
+	// JVB is the minimum number of bridges of each shard, it raises the
+	// floor of the autoscaler with the autoscaled strategy
+	//+optional
+	JVB *int32 `json:"jvb,omitempty"`
+	// Web is the minimum number of web replicas of each shard
+	//+optional
+	Web *int32 `json:"web,omitempty"`
+	// Jibri is the minimum number of recorders of each shard
+	//+optional
+	Jibri *int32 `json:"jibri,omitempty"`
+}
+
+// Shard is a signaling shard: a Prosody and Jicofo pair with its own web,
+// bridges and recorders
+type Shard struct {
+	// Name of the shard, used in the name of its objects and reported as
+	// DEPLOYMENTINFO_SHARD
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	//+kubebuilder:validation:MaxLength=20
+	Name string `json:"name"`
+	// Region overrides spec.region for the bridges and clients of the shard
+	//+optional
+	Region string `json:"region,omitempty"`
+	// JVBStrategy overrides spec.jvb.strategy for the bridges of the shard
+	//+optional
+	JVBStrategy *JVBStrategy `json:"jvbStrategy,omitempty"`
+}
+
+// Router routes the requests for a room to the shard hosting it
+type Router struct {
+	*ContainerRuntime `json:",inline"`
+	AffinitySettings  `json:",inline"`
+	//+optional
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
+type Sharding struct {
+	// Count is the number of shards, named shard-0 to shard-<count-1>.
+	// It is ignored when List is set.
+	//+optional
+	//+kubebuilder:validation:Minimum=1
+	Count int32 `json:"count,omitempty"`
+	// List of shards with their own overrides
+	//+optional
+	List []Shard `json:"list,omitempty"`
+	//+optional
+	Router Router `json:"router,omitempty"`
+}
+
+// RegionGroup is a set of regions whose bridges may be mixed in a conference
+type RegionGroup struct {
+	Regions []string `json:"regions"`
+}
+
+// FederationExpose publishes the XMPP port of the Prosody of every shard so
+// that bridges outside of the cluster can join their brewery
+type FederationExpose struct {
+	//+kubebuilder:validation:Enum=LoadBalancer;NodePort
+	//+optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+	//+optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// JitsiReference references a Jitsi of the same cluster
+type JitsiReference struct {
+	Name string `json:"name"`
+	// Namespace of the instance, the one of the referencing object when unset
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Upstream is an instance whose shards the bridges join in addition to the
+// local ones
+type Upstream struct {
+	// JitsiRef references an instance of the same cluster, its servers and
+	// bridge password are resolved by the operator
+	//+optional
+	JitsiRef *JitsiReference `json:"jitsiRef,omitempty"`
+	// Servers are the host[:port] of the XMPP servers of a remote instance,
+	// e.g. the addresses published by its spec.federation.expose
+	//+optional
+	Servers []string `json:"servers,omitempty"`
+	// Secret holds the JVB_AUTH_PASSWORD of the remote instance
+	//+optional
+	Secret *corev1.SecretKeySelector `json:"secret,omitempty"`
+	//+optional
+	AuthDomain string `json:"authDomain,omitempty"`
+	//+optional
+	InternalMUCDomain string `json:"internalMucDomain,omitempty"`
+}
+
+// Federation connects the bridges of instances running in different
+// namespaces or clusters so that conferences can span regions
+type Federation struct {
+	//+optional
+	Expose *FederationExpose `json:"expose,omitempty"`
+	// RegionGroups are sets of regions Jicofo may mix in a conference
+	//+optional
+	RegionGroups []RegionGroup `json:"regionGroups,omitempty"`
+	// Upstream makes the bridges of this instance join the breweries of
+	// another instance
+	//+optional
+	Upstream *Upstream `json:"upstream,omitempty"`
+	// AllowedNamespaces are the namespaces whose instances may reference
+	// this one as their upstream.jitsiRef, which copies its bridge password
+	// into their namespace. Instances of its own namespace always may.
+	//+optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+// Teardown configures how an instance is taken down when it is deleted
+type Teardown struct {
+	// WaitForConferences delays the deletion until no conference nor
+	// recording is running anymore
+	//+optional
+	WaitForConferences bool `json:"waitForConferences,omitempty"`
+	// Timeout is how long the deletion waits for conferences and recordings
+	// to end before it proceeds anyway, 1h by default
+	//+optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
 }
 
 // JitsiSpec defines the desired state of Jitsi
@@ -178,12 +865,133 @@ type JitsiSpec struct {
 	Ingress Ingress `json:"ingress,omitempty"`
 	//+optional
 	TURN *TURN `json:"turn,omitempty"`
+	// ICEServers are offered to the clients by Prosody along spec.turn. The
+	// STUN servers are queried by the bridges too, in place of the public
+	// jitsi.net one.
+	//+optional
+	ICEServers []ICEServer `json:"iceServers,omitempty"`
+	//+optional
+	Secrets *Secrets `json:"secrets,omitempty"`
+	// Auth sets the authentication of the users on Prosody, Jicofo and Web,
+	// overriding the matching variables
+	//+optional
+	Auth *Auth `json:"auth,omitempty"`
 	//+optional
 	Metrics bool `json:"metrics,omitempty"`
 	//+optional
 	Suspend bool `json:"suspend,omitempty"`
 	//+optional
 	DisableGracefulUpgrade bool `json:"disableGracefulUpgrade,omitempty"`
+	//+optional
+	Upgrade *UpgradeStrategy `json:"upgrade,omitempty"`
+	//+optional
+	Teardown *Teardown `json:"teardown,omitempty"`
+	// Shards split the signaling over several Prosody and Jicofo pairs. An
+	// instance without shards runs a single one.
+	//+optional
+	Shards *Sharding `json:"shards,omitempty"`
+	//+optional
+	Federation *Federation `json:"federation,omitempty"`
+	// CapacitySchedules raise the replicas of the components at given times
+	// of the day or week. The first schedule open applies.
+	//+optional
+	CapacitySchedules []CapacitySchedule `json:"capacitySchedules,omitempty"`
+}
+
+// Condition types reported in JitsiStatus.Conditions
+const (
+	// ConditionReady is true when every enabled component has all its replicas ready
+	ConditionReady = "Ready"
+	// ConditionProgressing is true while components are being rolled out or scaled
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded is true when the last reconciliation failed
+	ConditionDegraded = "Degraded"
+	// ConditionSuspended is true when reconciliation is suspended through spec.suspend
+	ConditionSuspended = "Suspended"
+	// ConditionUpgradeBlocked is true when an operator upgrade waits for conferences to end
+	ConditionUpgradeBlocked = "UpgradeBlocked"
+	// ConditionSecretsRolloutBlocked is true when new passwords wait for conferences to end
+	ConditionSecretsRolloutBlocked = "SecretsRolloutBlocked"
+	// ConditionTerminating is true while a deleted instance is being taken down
+	ConditionTerminating = "Terminating"
+)
+
+// ComponentStatus holds the replica counts observed for a component
+type ComponentStatus struct {
+	//+optional
+	Replicas int32 `json:"replicas"`
+	//+optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+	// UpdatedReplicas run the latest pod template of the component
+	//+optional
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+}
+
+// Ready reports whether the component has at least one replica and all of
+// them run the latest pod template and are ready
+func (s *ComponentStatus) Ready() bool {
+	return s.Replicas > 0 && s.ReadyReplicas >= s.Replicas && s.UpdatedReplicas >= s.Replicas
+}
+
+// ConferenceStats holds the load reported by the Jicofo /stats endpoint
+type ConferenceStats struct {
+	//+optional
+	Conferences int64 `json:"conferences"`
+	//+optional
+	Participants int64 `json:"participants"`
+	//+optional
+	LargestConference int64 `json:"largestConference"`
+	//+optional
+	Bridges int64 `json:"bridges"`
+	//+optional
+	OperationalBridges int64 `json:"operationalBridges"`
+	//+optional
+	JibriInstances int64 `json:"jibriInstances"`
+	//+optional
+	JibriAvailable int64 `json:"jibriAvailable"`
+	// Stale is true when the last scrape failed and the values are those of the last successful one
+	//+optional
+	Stale bool `json:"stale,omitempty"`
+	//+optional
+	Message string `json:"message,omitempty"`
+	//+optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// UpgradeStatus describes an operator upgrade waiting to be rolled out
+type UpgradeStatus struct {
+	TargetRevision string `json:"targetRevision"`
+	//+optional
+	Reason string `json:"reason,omitempty"`
+	//+optional
+	Message string `json:"message,omitempty"`
+	//+optional
+	PendingSince *metav1.Time `json:"pendingSince,omitempty"`
+	//+optional
+	Draining bool `json:"draining,omitempty"`
+}
+
+// SecretsStatus describes the passwords the components run with
+type SecretsStatus struct {
+	// Version is a hash of the passwords, the components restart when it
+	// changes
+	//+optional
+	Version string `json:"version,omitempty"`
+	// Components are the versions of the passwords each component runs
+	// with. New passwords roll out to Prosody first, then Jicofo, then the
+	// bridges.
+	//+optional
+	Components map[string]string `json:"components,omitempty"`
+	// PendingSince is when new passwords started waiting for a maintenance
+	// window or for the conferences to end
+	//+optional
+	PendingSince *metav1.Time `json:"pendingSince,omitempty"`
+	//+optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// RotationRequest is the last value of the rotate-secrets annotation
+	// acted upon
+	//+optional
+	RotationRequest string `json:"rotationRequest,omitempty"`
 }
 
 // JitsiStatus defines the observed state of Jitsi
@@ -192,10 +1000,60 @@ type JitsiStatus struct {
 	// Important: Run "make" to regenerate code after modifying this file
 	LastAppliedRevision   string `json:"lastAppliedRevision,omitempty"`
 	LastAttemptedRevision string `json:"lastAttemptedRevision,omitempty"`
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	//+optional
+	Prosody *ComponentStatus `json:"prosody,omitempty"`
+	//+optional
+	Jicofo *ComponentStatus `json:"jicofo,omitempty"`
+	//+optional
+	JVB *ComponentStatus `json:"jvb,omitempty"`
+	//+optional
+	Web *ComponentStatus `json:"web,omitempty"`
+	//+optional
+	Jibri *ComponentStatus `json:"jibri,omitempty"`
+	//+optional
+	Stats *ConferenceStats `json:"stats,omitempty"`
+	//+optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+	// CapacitySchedule is the name of the capacity schedule applied
+	//+optional
+	CapacitySchedule string `json:"capacitySchedule,omitempty"`
+	//+optional
+	Secrets *SecretsStatus `json:"secrets,omitempty"`
+	// ContentVersions are hashes of the ConfigMaps and Secrets referenced by
+	// each component, its pods roll out when they change
+	//+optional
+	ContentVersions map[string]string `json:"contentVersions,omitempty"`
+	//+optional
+	Upstream *UpstreamStatus `json:"upstream,omitempty"`
+}
+
+// UpstreamStatus holds the servers resolved from spec.federation.upstream.jitsiRef
+type UpstreamStatus struct {
+	//+optional
+	Servers []string `json:"servers,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Domain",type=string,JSONPath=`.spec.domain`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`,priority=1
+//+kubebuilder:printcolumn:name="JVB",type=integer,JSONPath=`.status.jvb.readyReplicas`
+//+kubebuilder:printcolumn:name="Web",type=integer,JSONPath=`.status.web.readyReplicas`,priority=1
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.status.capacitySchedule`,priority=1
+//+kubebuilder:printcolumn:name="Conferences",type=integer,JSONPath=`.status.stats.conferences`,priority=1
+//+kubebuilder:printcolumn:name="Participants",type=integer,JSONPath=`.status.stats.participants`,priority=1
+//+kubebuilder:printcolumn:name="Largest",type=integer,JSONPath=`.status.stats.largestConference`,priority=1
+//+kubebuilder:printcolumn:name="Bridges",type=integer,JSONPath=`.status.stats.bridges`,priority=1
+//+kubebuilder:printcolumn:name="Stale",type=boolean,JSONPath=`.status.stats.stale`,priority=1
+//+kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.status.lastAppliedRevision`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Jitsi is the Schema for the jitsis API
 type Jitsi struct {
@@ -0,0 +1,342 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func (jitsi *Jitsi) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(jitsi).
+		WithDefaulter(&jitsiWebhook{}).
+		WithValidator(&jitsiWebhook{}).
+		Complete()
+}
+
+// jitsiWebhook defaults and validates the instances on admission
+type jitsiWebhook struct{}
+
+func asJitsi(obj runtime.Object) (*Jitsi, error) {
+	jitsi, ok := obj.(*Jitsi)
+	if !ok {
+		return nil, fmt.Errorf("expected a Jitsi but got a %T", obj)
+	}
+	return jitsi, nil
+}
+
+//+kubebuilder:webhook:path=/mutate-apps-jit-si-v1alpha1-jitsi,mutating=true,failurePolicy=fail,sideEffects=None,groups=apps.jit.si,resources=jitsis,verbs=create;update,versions=v1alpha1,name=mjitsi.kb.io,admissionReviewVersions=v1
+
+var _ admission.CustomDefaulter = &jitsiWebhook{}
+
+// Default implements admission.CustomDefaulter so a webhook will be registered for the type
+func (w *jitsiWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	jitsi, err := asJitsi(obj)
+	if err != nil {
+		return err
+	}
+	jitsi.SetSpecDefaults()
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-apps-jit-si-v1alpha1-jitsi,mutating=false,failurePolicy=fail,sideEffects=None,groups=apps.jit.si,resources=jitsis,verbs=create;update,versions=v1alpha1,name=vjitsi.kb.io,admissionReviewVersions=v1
+
+var _ admission.CustomValidator = &jitsiWebhook{}
+
+// ValidateCreate implements admission.CustomValidator so a webhook will be registered for the type
+func (w *jitsiWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	jitsi, err := asJitsi(obj)
+	if err != nil {
+		return nil, err
+	}
+	return jitsi.warnings(), jitsi.invalid(jitsi.validateSpec())
+}
+
+// ValidateUpdate implements admission.CustomValidator so a webhook will be registered for the type
+func (w *jitsiWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldJitsi, err := asJitsi(oldObj)
+	if err != nil {
+		return nil, err
+	}
+	jitsi, err := asJitsi(newObj)
+	if err != nil {
+		return nil, err
+	}
+
+	// the finalizer of an instance being torn down must always be removable
+	if jitsi.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	// only a changed spec is checked, so that updates of the metadata, e.g.
+	// the finalizer, go through for instances stored before a rule existed.
+	// The new spec went through the defaulting webhook, the old one may not.
+	errs := field.ErrorList{}
+	oldSpec := oldJitsi.DeepCopy()
+	oldSpec.SetSpecDefaults()
+	if !equality.Semantic.DeepEqual(oldSpec.Spec, jitsi.Spec) {
+		errs = jitsi.validateSpec()
+	}
+	if oldJitsi.Spec.Domain != jitsi.Spec.Domain {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "domain"), "field is immutable"))
+	}
+
+	warnings := jitsi.warnings()
+	if oldJitsi.Sharded() != jitsi.Sharded() {
+		warnings = append(warnings, "switching spec.shards on or off recreates the signaling components under new names, running conferences are interrupted")
+	}
+
+	return warnings, jitsi.invalid(errs)
+}
+
+// ValidateDelete implements admission.CustomValidator so a webhook will be registered for the type
+func (w *jitsiWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// Validate checks the spec is consistent, it is the check done by the
+// validating webhook minus the immutable fields
+func (jitsi *Jitsi) Validate() error {
+	return jitsi.invalid(jitsi.validateSpec())
+}
+
+func (jitsi *Jitsi) invalid(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Jitsi").GroupKind(), jitsi.Name, errs)
+}
+
+func validatePort(path *field.Path, port *int32) *field.Error {
+	if port != nil && (*port < 1 || *port > 65535) {
+		return field.Invalid(path, *port, "must be between 1 and 65535")
+	}
+	return nil
+}
+
+func (jitsi *Jitsi) validateSpec() field.ErrorList {
+	errs := field.ErrorList{}
+	spec := field.NewPath("spec")
+
+	if len(jitsi.Spec.Domain) == 0 {
+		errs = append(errs, field.Required(spec.Child("domain"), "the public domain of the instance is required"))
+	}
+
+	if len(jitsi.Spec.Timezone) > 0 {
+		if _, err := time.LoadLocation(jitsi.Spec.Timezone); err != nil {
+			errs = append(errs, field.Invalid(spec.Child("timezone"), jitsi.Spec.Timezone, "unknown time zone"))
+		}
+	}
+
+	jvb := spec.Child("jvb")
+	strategy := jitsi.Spec.JVB.Strategy
+	if strategy.Replicas != nil && *strategy.Replicas < 0 {
+		errs = append(errs, field.Invalid(jvb.Child("strategy", "replicas"), *strategy.Replicas, "must not be negative"))
+	}
+	if strategy.Type == JVBStrategyAutoScaled {
+		if strategy.MaxReplicas < 1 {
+			errs = append(errs, field.Required(jvb.Child("strategy", "maxReplicas"), "required by the autoscaled strategy"))
+		} else if strategy.Replicas != nil && strategy.MaxReplicas < *strategy.Replicas {
+			errs = append(errs, field.Invalid(jvb.Child("strategy", "maxReplicas"), strategy.MaxReplicas, "must not be lower than replicas"))
+		}
+	}
+	if strategy.Autoscaler != nil {
+		errs = append(errs, strategy.Autoscaler.validate(jvb.Child("strategy", "autoscaler"))...)
+	}
+	if publicIP := jitsi.Spec.JVB.PublicIP; publicIP != nil {
+		errs = append(errs, publicIP.validate(jvb.Child("publicIP"))...)
+	}
+	errs = append(errs, jitsi.Spec.JVB.validateExposure(jvb.Child("exposure"))...)
+	if err := validatePort(jvb.Child("ports", "udp"), jitsi.Spec.JVB.Ports.UDP); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validatePort(jvb.Child("ports", "tcp"), jitsi.Spec.JVB.Ports.TCP); err != nil {
+		errs = append(errs, err)
+	}
+	if tcp := jitsi.Spec.JVB.TCP; tcp != nil {
+		if err := validatePort(jvb.Child("tcp", "advertisedPort"), tcp.AdvertisedPort); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, jitsi.Spec.Prosody.validate(spec.Child("prosody"))...)
+	errs = append(errs, jitsi.Spec.JVB.PodOverrides.validate(jvb)...)
+	errs = append(errs, jitsi.Spec.Prosody.PodOverrides.validate(spec.Child("prosody"))...)
+	errs = append(errs, jitsi.Spec.Jicofo.PodOverrides.validate(spec.Child("jicofo"))...)
+	errs = append(errs, jitsi.Spec.Jibri.PodOverrides.validate(spec.Child("jibri"))...)
+	errs = append(errs, jitsi.Spec.Web.PodOverrides.validate(spec.Child("web"))...)
+	errs = append(errs, jitsi.Spec.JVB.ComponentEnv.validate(jvb)...)
+	errs = append(errs, jitsi.Spec.Prosody.ComponentEnv.validate(spec.Child("prosody"))...)
+	errs = append(errs, jitsi.Spec.Jicofo.ComponentEnv.validate(spec.Child("jicofo"))...)
+	errs = append(errs, jitsi.Spec.Jibri.ComponentEnv.validate(spec.Child("jibri"))...)
+	errs = append(errs, jitsi.Spec.Web.ComponentEnv.validate(spec.Child("web"))...)
+
+	if bucket := jitsi.Spec.Jibri.Bucket; bucket != nil {
+		path := spec.Child("jibri", "bucket")
+		if bucket.Secret == nil || len(bucket.Secret.Name) == 0 {
+			errs = append(errs, field.Required(path.Child("secret"), "a secret holding ACCESS_KEY and SECRET_KEY is required"))
+		}
+		if len(bucket.Host) == 0 {
+			errs = append(errs, field.Required(path.Child("host"), ""))
+		}
+		if len(bucket.Name) == 0 {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		}
+	}
+
+	if turn := jitsi.Spec.TURN; turn != nil {
+		errs = append(errs, turn.validate(spec.Child("turn"))...)
+	}
+	for i := range jitsi.Spec.ICEServers {
+		errs = append(errs, jitsi.Spec.ICEServers[i].validate(spec.Child("iceServers").Index(i))...)
+	}
+
+	if upgrade := jitsi.Spec.Upgrade; upgrade != nil {
+		path := spec.Child("upgrade")
+		for i := range upgrade.MaintenanceWindows {
+			if err := upgrade.MaintenanceWindows[i].Validate(); err != nil {
+				errs = append(errs, field.Invalid(path.Child("maintenanceWindows").Index(i), upgrade.MaintenanceWindows[i], err.Error()))
+			}
+		}
+		if upgrade.MaxWait != nil && upgrade.MaxWait.Duration < 0 {
+			errs = append(errs, field.Invalid(path.Child("maxWait"), upgrade.MaxWait.Duration.String(), "must not be negative"))
+		}
+	}
+
+	if shards := jitsi.Spec.Shards; shards != nil {
+		path := spec.Child("shards")
+		if len(shards.List) > 0 && shards.Count > 0 {
+			errs = append(errs, field.Forbidden(path.Child("count"), "count and list are mutually exclusive"))
+		}
+		if shards.Count < 0 {
+			errs = append(errs, field.Invalid(path.Child("count"), shards.Count, "must not be negative"))
+		}
+		names := map[string]bool{}
+		for i, shard := range shards.List {
+			if shard.JVBStrategy != nil && shard.JVBStrategy.Autoscaler != nil {
+				errs = append(errs, shard.JVBStrategy.Autoscaler.validate(path.Child("list").Index(i).Child("jvbStrategy", "autoscaler"))...)
+			}
+			if len(shard.Name) == 0 {
+				errs = append(errs, field.Required(path.Child("list").Index(i).Child("name"), ""))
+			} else if names[shard.Name] {
+				errs = append(errs, field.Duplicate(path.Child("list").Index(i).Child("name"), shard.Name))
+			}
+			names[shard.Name] = true
+		}
+
+		daemonSets := 0
+		all := jitsi.Shards()
+		for i := range all {
+			strategy := jitsi.JVBStrategy(&all[i])
+			if strategy.Type == JVBStrategyDaemon {
+				daemonSets++
+			}
+			if all[i].JVBStrategy != nil && strategy.Type == JVBStrategyAutoScaled && strategy.MaxReplicas < 1 {
+				errs = append(errs, field.Required(path.Child("list").Index(i).Child("jvbStrategy", "maxReplicas"), "required by the autoscaled strategy"))
+			}
+		}
+		// bridges of a daemonset run on every node with the same host port
+		if daemonSets > 1 {
+			errs = append(errs, field.Invalid(path, daemonSets, "the daemonset jvb strategy can only be used by a single shard"))
+		}
+	}
+
+	if federation := jitsi.Spec.Federation; federation != nil {
+		path := spec.Child("federation")
+		for i, group := range federation.RegionGroups {
+			if len(group.Regions) == 0 {
+				errs = append(errs, field.Required(path.Child("regionGroups").Index(i).Child("regions"), ""))
+			}
+		}
+		if upstream := federation.Upstream; upstream != nil {
+			path := path.Child("upstream")
+			switch {
+			case upstream.JitsiRef != nil && len(upstream.Servers) > 0:
+				errs = append(errs, field.Forbidden(path.Child("servers"), "servers and jitsiRef are mutually exclusive"))
+			case upstream.JitsiRef != nil:
+				if upstream.JitsiRef.Name == jitsi.Name && (upstream.JitsiRef.Namespace == "" || upstream.JitsiRef.Namespace == jitsi.Namespace) {
+					errs = append(errs, field.Invalid(path.Child("jitsiRef"), upstream.JitsiRef.Name, "an instance cannot be its own upstream"))
+				}
+			case len(upstream.Servers) == 0:
+				errs = append(errs, field.Required(path, "either jitsiRef or servers is required"))
+			case upstream.Secret == nil:
+				errs = append(errs, field.Required(path.Child("secret"), "the JVB_AUTH_PASSWORD of the remote instance is required with servers"))
+			}
+		}
+	}
+
+	if secrets := jitsi.Spec.Secrets; secrets != nil {
+		errs = append(errs, secrets.validate(spec.Child("secrets"))...)
+	}
+
+	if auth := jitsi.Spec.Auth; auth != nil {
+		errs = append(errs, auth.validate(spec.Child("auth"))...)
+	}
+
+	schedules := map[string]bool{}
+	for i := range jitsi.Spec.CapacitySchedules {
+		schedule := &jitsi.Spec.CapacitySchedules[i]
+		path := spec.Child("capacitySchedules").Index(i)
+		if len(schedule.Name) == 0 {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else if schedules[schedule.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), schedule.Name))
+		}
+		schedules[schedule.Name] = true
+		if err := schedule.Validate(); err != nil {
+			errs = append(errs, field.Invalid(path, schedule.Name, err.Error()))
+		}
+	}
+
+	if teardown := jitsi.Spec.Teardown; teardown != nil && teardown.Timeout != nil && teardown.Timeout.Duration < 0 {
+		errs = append(errs, field.Invalid(spec.Child("teardown", "timeout"), teardown.Timeout.Duration.String(), "must not be negative"))
+	}
+
+	return errs
+}
+
+func (jitsi *Jitsi) warnings() admission.Warnings {
+	warnings := admission.Warnings{}
+
+	if jitsi.Spec.Upgrade != nil && jitsi.Spec.Upgrade.Drain && !jitsi.Spec.JVB.GracefulShutdown {
+		warnings = append(warnings, "spec.upgrade.drain has no effect without spec.jvb.gracefulShutdown")
+	}
+	if jitsi.Spec.JVB.Strategy.Type == JVBStrategyDaemon && jitsi.Spec.JVB.Strategy.Replicas != nil && *jitsi.Spec.JVB.Strategy.Replicas != 1 {
+		warnings = append(warnings, "spec.jvb.strategy.replicas is ignored by the daemonset strategy")
+	}
+	if autoscaler := jitsi.Spec.JVB.Strategy.AutoscalerSettings(); (autoscaler.Backend == JVBAutoscalerPrometheusAdapter || autoscaler.Backend == JVBAutoscalerKEDA) && !jitsi.Spec.Metrics {
+		warnings = append(warnings, "the "+string(autoscaler.Backend)+" backend needs the bridges to be scraped by Prometheus, e.g. with spec.metrics")
+	}
+	warnings = append(warnings, jitsi.Spec.JVB.exposureWarnings(field.NewPath("spec", "jvb", "exposure"))...)
+	warnings = append(warnings, jitsi.authWarnings()...)
+	warnings = append(warnings, jitsi.variablesWarnings()...)
+	warnings = append(warnings, jitsi.Spec.Prosody.warnings()...)
+	if _, set := jitsi.Annotations[RotateSecretsAnnotation]; set && jitsi.ExternalSecrets() {
+		warnings = append(warnings, "the "+RotateSecretsAnnotation+" annotation has no effect on the existing secret of spec.secrets.name")
+	}
+
+	return warnings
+}
@@ -0,0 +1,41 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// XMPPDomain returns the domain the users of the instance log in, as Prosody
+// is configured
+func (jitsi *Jitsi) XMPPDomain() string {
+	if domain := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "XMPP_DOMAIN"); len(domain) > 0 {
+		return domain
+	}
+	return "meet.jitsi"
+}
+
+// InternalAuth tells whether Prosody authenticates the users it registers
+func (jitsi *Jitsi) InternalAuth() bool {
+	enabled := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "ENABLE_AUTH")
+	authType := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "AUTH_TYPE")
+	return (enabled == "1" || enabled == "true") && (authType == "" || authType == string(AuthInternal))
+}
+
+// JID returns the address the user logs in with on the instance
+func (user *JitsiUser) JID(jitsi *Jitsi) string {
+	domain := user.Spec.Domain
+	if len(domain) == 0 {
+		domain = jitsi.XMPPDomain()
+	}
+	return user.Spec.Username + "@" + domain
+}
+
+func (user *JitsiUser) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&user.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: user.Generation,
+	})
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// JitsiUserSpec defines the desired state of JitsiUser
+type JitsiUserSpec struct {
+	// JitsiRef references the instance of the same namespace the user logs
+	// in, which authenticates its users with the internal type
+	JitsiRef corev1.LocalObjectReference `json:"jitsiRef"`
+	//+kubebuilder:validation:Pattern=`^[^@/\s]+$`
+	Username string `json:"username"`
+	// Domain is the XMPP domain of the user, the XMPP_DOMAIN of the
+	// instance by default
+	//+optional
+	Domain string `json:"domain,omitempty"`
+	// Password references the key of a Secret of the same namespace holding
+	// the password of the user
+	Password corev1.SecretKeySelector `json:"password"`
+	// Moderator makes the user a moderator of every conference, not only of
+	// those it opens. Prosody reloads its configuration when the moderators
+	// change.
+	//+optional
+	Moderator bool `json:"moderator,omitempty"`
+}
+
+// JitsiUserStatus defines the observed state of JitsiUser
+type JitsiUserStatus struct {
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// JID the user logs in with
+	//+optional
+	JID string `json:"jid,omitempty"`
+	// Pods are the Prosody pods the account is registered on
+	//+optional
+	Pods []string `json:"pods,omitempty"`
+	// Containers are the Prosody containers the account is registered in, a
+	// restarted container has lost it
+	//+optional
+	Containers []string `json:"containers,omitempty"`
+	// PasswordVersion is the resource version of the Secret the password was
+	// last read from
+	//+optional
+	PasswordVersion string `json:"passwordVersion,omitempty"`
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Jitsi",type=string,JSONPath=`.spec.jitsiRef.name`
+//+kubebuilder:printcolumn:name="JID",type=string,JSONPath=`.status.jid`
+//+kubebuilder:printcolumn:name="Moderator",type=boolean,JSONPath=`.spec.moderator`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// JitsiUser is an account of the Prosody of a Jitsi instance
+type JitsiUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   JitsiUserSpec   `json:"spec,omitempty"`
+	Status JitsiUserStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// JitsiUserList contains a list of JitsiUser
+type JitsiUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []JitsiUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&JitsiUser{}, &JitsiUserList{})
+}
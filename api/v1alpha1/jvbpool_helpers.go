@@ -0,0 +1,140 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// PoolLabel holds the pool of the bridges of a JVBPool
+const PoolLabel = "apps.jit.si/jvb-pool"
+
+// Labels returns the labels of the bridges of the pool. They carry the labels
+// of the instance but not its jvb component, so that the selectors and the
+// autoscaler of the bridges of the instance never match those of a pool.
+func (pool *JVBPool) Labels(jitsi *Jitsi) labels.Set {
+	l := jitsi.ComponentLabels("jvb-pool")
+	l[PoolLabel] = pool.Name
+
+	return l
+}
+
+// SetDefaults fills in the unset fields of the pool from the bridges of the
+// defaulted instance
+func (pool *JVBPool) SetDefaults(jitsi *Jitsi) {
+	if pool.Spec.ContainerRuntime == nil {
+		pool.Spec.ContainerRuntime = &ContainerRuntime{}
+	}
+
+	if len(pool.Spec.Image) == 0 {
+		pool.Spec.Image = jitsi.Spec.JVB.Image
+	}
+
+	if len(pool.Spec.ImagePullPolicy) == 0 {
+		pool.Spec.ImagePullPolicy = jitsi.Spec.JVB.ImagePullPolicy
+	}
+
+	if len(pool.Spec.Strategy.Type) == 0 {
+		pool.Spec.Strategy.Type = JVBStrategyStatic
+	}
+
+	if pool.Spec.Strategy.Replicas == nil {
+		defaultReplicas := int32(1)
+		pool.Spec.Strategy.Replicas = &defaultReplicas
+	}
+
+	if pool.Spec.Ports.UDP == nil {
+		pool.Spec.Ports.UDP = jitsi.Spec.JVB.Ports.UDP
+	}
+
+	if pool.Spec.Ports.TCP == nil {
+		pool.Spec.Ports.TCP = jitsi.Spec.JVB.Ports.TCP
+	}
+
+	if pool.Spec.TCP == nil {
+		pool.Spec.TCP = jitsi.Spec.JVB.TCP
+	}
+
+	if pool.Spec.Exposure == nil {
+		pool.Spec.Exposure = jitsi.Spec.JVB.Exposure
+	}
+
+	if pool.Spec.PublicIP == nil {
+		pool.Spec.PublicIP = jitsi.Spec.JVB.PublicIP
+	} else {
+		pool.Spec.PublicIP.setDefaults()
+	}
+}
+
+// Shard returns the shard of jitsi the bridges of the pool join, with the
+// region of the pool
+func (pool *JVBPool) Shard(jitsi *Jitsi) (*Shard, error) {
+	var shard *Shard
+
+	if !jitsi.Sharded() {
+		if len(pool.Spec.Shard) > 0 {
+			return nil, fmt.Errorf("jitsi %s is not sharded, spec.shard must be empty", jitsi.Name)
+		}
+		shard = &Shard{}
+	} else {
+		if len(pool.Spec.Shard) == 0 {
+			return nil, fmt.Errorf("jitsi %s is sharded, spec.shard is required", jitsi.Name)
+		}
+		shards := jitsi.Shards()
+		for i := range shards {
+			if shards[i].Name == pool.Spec.Shard {
+				found := shards[i]
+				shard = &found
+			}
+		}
+		if shard == nil {
+			return nil, fmt.Errorf("jitsi %s has no shard %s", jitsi.Name, pool.Spec.Shard)
+		}
+	}
+
+	if len(pool.Spec.Region) > 0 {
+		shard.Region = pool.Spec.Region
+	}
+	shard.JVBStrategy = nil
+
+	return shard, nil
+}
+
+// Validate checks the defaulted spec of the pool
+func (pool *JVBPool) Validate() error {
+	if pool.Spec.Strategy.Type == JVBStrategyAutoScaled && pool.Spec.Strategy.MaxReplicas < *pool.Spec.Strategy.Replicas {
+		return fmt.Errorf("spec.strategy.maxReplicas must be at least %d with the autoscaled strategy", *pool.Spec.Strategy.Replicas)
+	}
+	if *pool.Spec.Ports.UDP < 1 || *pool.Spec.Ports.UDP > 65535 {
+		return fmt.Errorf("spec.ports.udp must be between 1 and 65535")
+	}
+	if publicIP := pool.Spec.PublicIP; publicIP != nil {
+		if errs := publicIP.validate(field.NewPath("spec", "publicIP")); len(errs) > 0 {
+			return errs.ToAggregate()
+		}
+	}
+	if errs := pool.Spec.validateExposure(field.NewPath("spec", "exposure")); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	if errs := append(pool.Spec.PodOverrides.validate(field.NewPath("spec")), pool.Spec.ComponentEnv.validate(field.NewPath("spec"))...); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	if autoscaler := pool.Spec.Strategy.Autoscaler; autoscaler != nil {
+		return autoscaler.validate(field.NewPath("spec", "strategy", "autoscaler")).ToAggregate()
+	}
+
+	return nil
+}
+
+func (pool *JVBPool) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&pool.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: pool.Generation,
+	})
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// JVBPoolSpec defines the desired state of JVBPool
+type JVBPoolSpec struct {
+	// JitsiRef references the instance of the same namespace whose
+	// brewery the bridges join
+	JitsiRef corev1.LocalObjectReference `json:"jitsiRef"`
+	// Shard of the instance the bridges join, required when the instance
+	// is sharded
+	//+optional
+	Shard string `json:"shard,omitempty"`
+	JVB   `json:",inline"`
+	// Region overrides the region of the shard for the bridges of the pool
+	//+optional
+	Region string `json:"region,omitempty"`
+}
+
+// JVBPoolStatus defines the observed state of JVBPool
+type JVBPoolStatus struct {
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	//+optional
+	Replicas int32 `json:"replicas"`
+	//+optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Jitsi",type=string,JSONPath=`.spec.jitsiRef.name`
+//+kubebuilder:printcolumn:name="Shard",type=string,JSONPath=`.spec.shard`,priority=1
+//+kubebuilder:printcolumn:name="Strategy",type=string,JSONPath=`.spec.strategy.type`
+//+kubebuilder:printcolumn:name="Region",type=string,JSONPath=`.spec.region`
+//+kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.replicas`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// JVBPool is an additional group of bridges of a Jitsi instance
+type JVBPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   JVBPoolSpec   `json:"spec,omitempty"`
+	Status JVBPoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// JVBPoolList contains a list of JVBPool
+type JVBPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []JVBPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&JVBPool{}, &JVBPoolList{})
+}
@@ -0,0 +1,90 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// JSON returns the patch converted from YAML
+func (patch *PodPatch) JSON() ([]byte, error) {
+	data, err := yaml.YAMLToJSON([]byte(patch.Patch))
+	if err != nil {
+		return nil, err
+	}
+
+	switch patch.Type {
+	case PodPatchJSON:
+		operations, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return nil, err
+		}
+		for i, operation := range operations {
+			if err := idempotent(operation); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		}
+	default:
+		object := map[string]interface{}{}
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, fmt.Errorf("a strategic merge patch must be an object: %w", err)
+		}
+	}
+
+	return data, nil
+}
+
+// idempotent checks the operation changes the template the same way when
+// applied again, as the patches are applied at every reconciliation on top
+// of the fields the operator does not rebuild, e.g. imagePullSecrets
+func idempotent(operation jsonpatch.Operation) error {
+	path, err := operation.Path()
+	if err != nil {
+		return err
+	}
+	switch operation.Kind() {
+	case "replace", "test":
+		return nil
+	case "add":
+		last := path[strings.LastIndex(path, "/")+1:]
+		if _, err := strconv.Atoi(last); err == nil || last == "-" {
+			return fmt.Errorf("add inserts into the list %s at every reconciliation, use replace or a strategic merge patch", path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s is not idempotent, only add, replace and test are supported", operation.Kind())
+	}
+}
+
+func (overrides *PodOverrides) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	for key := range overrides.PodAnnotations {
+		if strings.HasPrefix(key, GroupVersion.Group+"/") {
+			errs = append(errs, field.Invalid(path.Child("podAnnotations").Key(key), key, "annotations of "+GroupVersion.Group+" are reserved to the operator"))
+		}
+	}
+
+	names := map[string]bool{}
+	for i, container := range overrides.Sidecars {
+		if len(container.Name) == 0 {
+			errs = append(errs, field.Required(path.Child("sidecars").Index(i).Child("name"), ""))
+		} else if names[container.Name] {
+			errs = append(errs, field.Duplicate(path.Child("sidecars").Index(i).Child("name"), container.Name))
+		}
+		names[container.Name] = true
+	}
+
+	for i := range overrides.Patches {
+		if _, err := overrides.Patches[i].JSON(); err != nil {
+			errs = append(errs, field.Invalid(path.Child("patches").Index(i).Child("patch"), overrides.Patches[i].Patch, err.Error()))
+		}
+	}
+
+	return errs
+}
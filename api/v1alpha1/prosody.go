@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// envVarValue returns the value of the variable set by the config, ok is
+// false when the variable is left to the variables
+func (config *ProsodyConfig) envVarValue(name string) (value string, ok bool) {
+	switch name {
+	case "XMPP_MODULES":
+		return strings.Join(config.Modules, ","), len(config.Modules) > 0
+	case "XMPP_MUC_MODULES":
+		return strings.Join(config.MUCModules, ","), len(config.MUCModules) > 0
+	case "GLOBAL_MODULES":
+		return strings.Join(config.GlobalModules, ","), len(config.GlobalModules) > 0
+	case "ENABLE_LOBBY":
+		if config.Lobby != nil {
+			return boolValue(*config.Lobby), true
+		}
+	case "ENABLE_BREAKOUT_ROOMS":
+		if config.BreakoutRooms != nil {
+			return boolValue(*config.BreakoutRooms), true
+		}
+	case "DISABLE_POLLS":
+		if config.Polls != nil {
+			return boolValue(!*config.Polls), true
+		}
+	case "MAX_PARTICIPANTS":
+		if config.MaxParticipants != nil {
+			return strconv.FormatInt(int64(*config.MaxParticipants), 10), true
+		}
+	}
+
+	return "", false
+}
+
+func (prosody *Prosody) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	names := map[string]bool{}
+	for i, plugin := range prosody.Plugins {
+		path := path.Child("plugins").Index(i)
+		if names[plugin.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), plugin.Name))
+		}
+		names[plugin.Name] = true
+		if (plugin.ConfigMap == nil) == (len(plugin.Image) == 0) {
+			errs = append(errs, field.Required(path, "exactly one of configMap and image is required"))
+		}
+		if len(plugin.Path) > 0 && len(plugin.Image) == 0 {
+			errs = append(errs, field.Forbidden(path.Child("path"), "only used with an image"))
+		}
+	}
+
+	names = map[string]bool{}
+	for i, snippet := range prosody.Snippets {
+		path := path.Child("snippets").Index(i)
+		if names[snippet.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), snippet.Name))
+		}
+		names[snippet.Name] = true
+		if (snippet.ConfigMap == nil) == (len(snippet.Config) == 0) {
+			errs = append(errs, field.Required(path, "exactly one of config and configMap is required"))
+		}
+	}
+
+	return errs
+}
+
+func (prosody *Prosody) warnings() []string {
+	if prosody.CustomProsodyConfig != nil && prosody.Config != nil {
+		return []string{"spec.prosody.config is ignored by the jitsi-meet.cfg.lua of spec.prosody.customProsodyConfigCM"}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package v1alpha1
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// NodeBased reports whether the addresses are read from the Node objects by
+// the operator rather than from a metadata endpoint by the bridges
+func (p *PublicIP) NodeBased() bool {
+	return p.Source != PublicIPFromMetadata
+}
+
+// NodeAddresses returns the comma separated public addresses of the node
+func (p *PublicIP) NodeAddresses(node *corev1.Node) string {
+	switch p.Source {
+	case PublicIPFromLabel:
+		return node.Labels[p.Key]
+	case PublicIPFromAnnotation:
+		return node.Annotations[p.Key]
+	}
+
+	addresses := []string{}
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeExternalIP {
+			addresses = append(addresses, address.Address)
+		}
+	}
+	return strings.Join(addresses, ",")
+}
+
+func (p *PublicIP) setDefaults() {
+	if len(p.Source) == 0 {
+		p.Source = PublicIPFromExternalIP
+	}
+
+	if p.Source == PublicIPFromMetadata && len(p.MetadataImage) == 0 {
+		p.MetadataImage = "docker.io/library/busybox:1.36"
+	}
+}
+
+func (p *PublicIP) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	switch p.Source {
+	case PublicIPFromLabel, PublicIPFromAnnotation:
+		if len(p.Key) == 0 {
+			errs = append(errs, field.Required(path.Child("key"), "required by the "+string(p.Source)+" source"))
+		}
+	case PublicIPFromMetadata:
+		if len(p.MetadataURL) == 0 {
+			errs = append(errs, field.Required(path.Child("metadataURL"), "required by the Metadata source"))
+		}
+	}
+
+	return errs
+}
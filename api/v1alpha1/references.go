@@ -0,0 +1,170 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// References are the ConfigMaps and Secrets of the namespace a component
+// mounts or reads its environment from
+type References struct {
+	ConfigMaps []string
+	Secrets    []string
+}
+
+func (refs *References) addConfigMap(ref *corev1.LocalObjectReference) {
+	if ref != nil && len(ref.Name) > 0 {
+		refs.ConfigMaps = append(refs.ConfigMaps, ref.Name)
+	}
+}
+
+func (refs *References) addSecret(name string) {
+	if len(name) > 0 {
+		refs.Secrets = append(refs.Secrets, name)
+	}
+}
+
+func (refs *References) addSecretKey(ref *corev1.SecretKeySelector) {
+	if ref != nil {
+		refs.addSecret(ref.Name)
+	}
+}
+
+func (refs *References) addEnv(env []corev1.EnvVar, envFrom []corev1.EnvFromSource) {
+	for i := range env {
+		if from := env[i].ValueFrom; from != nil {
+			if from.ConfigMapKeyRef != nil {
+				refs.addConfigMap(&from.ConfigMapKeyRef.LocalObjectReference)
+			}
+			refs.addSecretKey(from.SecretKeyRef)
+		}
+	}
+	for i := range envFrom {
+		if ref := envFrom[i].ConfigMapRef; ref != nil {
+			refs.addConfigMap(&ref.LocalObjectReference)
+		}
+		if ref := envFrom[i].SecretRef; ref != nil {
+			refs.addSecret(ref.Name)
+		}
+	}
+}
+
+// addPod adds the ConfigMaps and Secrets the overrides and the environment
+// of a component read, the patches left aside
+func (refs *References) addPod(overrides *PodOverrides, env *ComponentEnv) {
+	refs.addEnv(env.Env, overrides.EnvFrom)
+	for _, container := range append(append([]corev1.Container{}, overrides.Sidecars...), overrides.ExtraInitContainers...) {
+		refs.addEnv(container.Env, container.EnvFrom)
+	}
+
+	for i := range overrides.ExtraVolumes {
+		volume := &overrides.ExtraVolumes[i].VolumeSource
+		if volume.ConfigMap != nil {
+			refs.addConfigMap(&volume.ConfigMap.LocalObjectReference)
+		}
+		if volume.Secret != nil {
+			refs.addSecret(volume.Secret.SecretName)
+		}
+		if volume.Projected == nil {
+			continue
+		}
+		for _, source := range volume.Projected.Sources {
+			if source.ConfigMap != nil {
+				refs.addConfigMap(&source.ConfigMap.LocalObjectReference)
+			}
+			if source.Secret != nil {
+				refs.addSecret(source.Secret.Name)
+			}
+		}
+	}
+}
+
+// References returns the ConfigMaps and Secrets referenced by the spec, by
+// component. The Secret of the components is left to their secrets version.
+func (jitsi *Jitsi) References() map[string]References {
+	prosody := References{}
+	prosody.addConfigMap(jitsi.Spec.Prosody.CustomProsodyConfig)
+	for _, plugin := range jitsi.Spec.Prosody.Plugins {
+		prosody.addConfigMap(plugin.ConfigMap)
+	}
+	for _, snippet := range jitsi.Spec.Prosody.Snippets {
+		if snippet.ConfigMap != nil {
+			prosody.addConfigMap(&snippet.ConfigMap.LocalObjectReference)
+		}
+	}
+	if turn := jitsi.Spec.TURN; turn != nil && turn.Managed == nil {
+		prosody.addSecretKey(turn.Secret)
+	}
+	for i := range jitsi.Spec.ICEServers {
+		prosody.addSecretKey(jitsi.Spec.ICEServers[i].Secret)
+		prosody.addSecretKey(jitsi.Spec.ICEServers[i].Password)
+	}
+	if auth := jitsi.Spec.Auth; auth != nil {
+		if auth.Type == AuthJWT && auth.JWT != nil {
+			prosody.addSecretKey(auth.JWT.AppSecret)
+		}
+		if auth.Type == AuthLDAP && auth.LDAP != nil && auth.LDAP.BindSecret != nil {
+			prosody.addSecret(auth.LDAP.BindSecret.Name)
+		}
+	}
+
+	prosody.addPod(&jitsi.Spec.Prosody.PodOverrides, &jitsi.Spec.Prosody.ComponentEnv)
+
+	web := References{}
+	web.addConfigMap(jitsi.Spec.Web.CustomConfig)
+	web.addConfigMap(jitsi.Spec.Web.CustomInterfaceConfig)
+	web.addConfigMap(jitsi.Spec.Web.CustomTitleConfig)
+	web.addConfigMap(jitsi.Spec.Web.CustomBodyConfig)
+	web.addConfigMap(jitsi.Spec.Web.CustomTranslationDeConfig)
+	web.addConfigMap(jitsi.Spec.Web.CustomCloseConfig)
+	web.addPod(&jitsi.Spec.Web.PodOverrides, &jitsi.Spec.Web.ComponentEnv)
+
+	jibri := References{}
+	if bucket := jitsi.Spec.Jibri.Bucket; bucket != nil && bucket.Secret != nil {
+		jibri.addSecret(bucket.Secret.Name)
+	}
+	jibri.addPod(&jitsi.Spec.Jibri.PodOverrides, &jitsi.Spec.Jibri.ComponentEnv)
+
+	jicofo := References{}
+	jicofo.addPod(&jitsi.Spec.Jicofo.PodOverrides, &jitsi.Spec.Jicofo.ComponentEnv)
+
+	jvb := References{}
+	jvb.addPod(&jitsi.Spec.JVB.PodOverrides, &jitsi.Spec.JVB.ComponentEnv)
+
+	refs := map[string]References{
+		"prosody": prosody,
+		"web":     web,
+		"jibri":   jibri,
+		"jicofo":  jicofo,
+		"jvb":     jvb,
+	}
+
+	if managed := jitsi.ManagedTURN(); managed != nil && jitsi.Spec.TURN.TLS {
+		turn := References{}
+		turn.addSecret(managed.TLSSecret)
+		refs["turn"] = turn
+	}
+
+	return refs
+}
+
+// Referenced tells whether the spec references the ConfigMap or Secret name,
+// the Secret of the components included
+func (jitsi *Jitsi) Referenced(kind string, name string) bool {
+	if kind == "Secret" && name == jitsi.SecretName() {
+		return true
+	}
+
+	for _, refs := range jitsi.References() {
+		names := refs.ConfigMaps
+		if kind == "Secret" {
+			names = refs.Secrets
+		}
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package v1alpha1
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// RotateSecretsAnnotation regenerates the passwords of the components when
+// set to a new value, e.g. the current date
+const RotateSecretsAnnotation = "apps.jit.si/rotate-secrets"
+
+// SecretName returns the name of the Secret holding the passwords of the
+// components
+func (jitsi *Jitsi) SecretName() string {
+	if jitsi.Spec.Secrets != nil && len(jitsi.Spec.Secrets.Name) > 0 {
+		return jitsi.Spec.Secrets.Name
+	}
+	return jitsi.Name
+}
+
+// ExternalSecrets tells whether the passwords are managed outside of the
+// operator
+func (jitsi *Jitsi) ExternalSecrets() bool {
+	return jitsi.Spec.Secrets != nil && len(jitsi.Spec.Secrets.Name) > 0
+}
+
+// RotationDue returns why the generated passwords are to be rotated at now,
+// an empty string when they are not
+func (jitsi *Jitsi) RotationDue(now time.Time) string {
+	if jitsi.ExternalSecrets() {
+		return ""
+	}
+
+	status := jitsi.Status.Secrets
+	if status == nil {
+		status = &SecretsStatus{}
+	}
+
+	if request := jitsi.Annotations[RotateSecretsAnnotation]; len(request) > 0 && request != status.RotationRequest {
+		return "requested by the " + RotateSecretsAnnotation + " annotation"
+	}
+
+	if jitsi.Spec.Secrets == nil || len(jitsi.Spec.Secrets.RotationSchedule) == 0 {
+		return ""
+	}
+	schedule, err := cron.ParseStandard(jitsi.Spec.Secrets.RotationSchedule)
+	if err != nil {
+		return ""
+	}
+	last := jitsi.CreationTimestamp.Time
+	if status.LastRotationTime != nil {
+		last = status.LastRotationTime.Time
+	}
+	if !schedule.Next(last.In(jitsi.Location())).After(now) {
+		return "scheduled by " + jitsi.Spec.Secrets.RotationSchedule
+	}
+	return ""
+}
+
+func (secrets *Secrets) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if len(secrets.RotationSchedule) > 0 {
+		if len(secrets.Name) > 0 {
+			errs = append(errs, field.Forbidden(path.Child("rotationSchedule"), "the passwords of an existing secret are rotated by whoever manages it"))
+		} else if _, err := cron.ParseStandard(secrets.RotationSchedule); err != nil {
+			errs = append(errs, field.Invalid(path.Child("rotationSchedule"), secrets.RotationSchedule, err.Error()))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,129 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/strings/slices"
+)
+
+// ShardLabel holds the shard of the objects of a sharded instance
+const ShardLabel = "apps.jit.si/shard"
+
+// Sharded reports whether the instance runs several signaling shards behind
+// a router
+func (jitsi *Jitsi) Sharded() bool {
+	return jitsi.Spec.Shards != nil
+}
+
+// Shards returns the signaling shards of the instance. An instance without
+// spec.shards has a single unnamed shard whose objects keep the names and
+// labels they had before sharding, as selectors cannot be changed.
+func (jitsi *Jitsi) Shards() []Shard {
+	if !jitsi.Sharded() {
+		return []Shard{{}}
+	}
+	if len(jitsi.Spec.Shards.List) > 0 {
+		return jitsi.Spec.Shards.List
+	}
+
+	shards := []Shard{{Name: "shard-0"}}
+	for i := int32(1); i < jitsi.Spec.Shards.Count; i++ {
+		shards = append(shards, Shard{Name: fmt.Sprintf("shard-%d", i)})
+	}
+	return shards
+}
+
+// ShardName returns the name of the objects of a component of the shard
+func (jitsi *Jitsi) ShardName(shard *Shard, component string) string {
+	if len(shard.Name) == 0 {
+		return fmt.Sprintf("%s-%s", jitsi.Name, component)
+	}
+	return fmt.Sprintf("%s-%s-%s", jitsi.Name, shard.Name, component)
+}
+
+// ShardLabels returns the labels of a component of the shard
+func (jitsi *Jitsi) ShardLabels(shard *Shard, component string) labels.Set {
+	l := jitsi.ComponentLabels(component)
+	if len(shard.Name) > 0 {
+		l[ShardLabel] = shard.Name
+	}
+
+	return l
+}
+
+// JVBStrategy returns the strategy of the bridges of the shard
+func (jitsi *Jitsi) JVBStrategy(shard *Shard) JVBStrategy {
+	if shard.JVBStrategy == nil {
+		return jitsi.Spec.JVB.Strategy
+	}
+
+	strategy := *shard.JVBStrategy
+	if len(strategy.Type) == 0 {
+		strategy.Type = jitsi.Spec.JVB.Strategy.Type
+	}
+	if strategy.Replicas == nil {
+		strategy.Replicas = jitsi.Spec.JVB.Strategy.Replicas
+	}
+	if strategy.MaxReplicas == 0 {
+		strategy.MaxReplicas = jitsi.Spec.JVB.Strategy.MaxReplicas
+	}
+	if strategy.Autoscaler == nil {
+		strategy.Autoscaler = jitsi.Spec.JVB.Strategy.Autoscaler
+	}
+	return strategy
+}
+
+// ShardEnvVarValue returns the value of the variable for the components of
+// the shard
+func (jitsi *Jitsi) ShardEnvVarValue(shard *Shard, name string) string {
+	return jitsi.shardEnvVarValue(shard, name, jitsi.Spec.Variables)
+}
+
+func (jitsi *Jitsi) shardEnvVarValue(shard *Shard, name string, variables map[string]string) string {
+	switch name {
+	case "XMPP_SERVER":
+		return jitsi.ShardName(shard, "prosody")
+	case "XMPP_BOSH_URL_BASE":
+		return fmt.Sprintf("http://%s.%s.svc.cluster.local:5280", jitsi.ShardName(shard, "prosody"), jitsi.Namespace)
+	case "DEPLOYMENTINFO_SHARD":
+		if len(shard.Name) > 0 {
+			return shard.Name
+		}
+	case "DEPLOYMENTINFO_USERREGION", "JVB_OCTO_REGION", "JICOFO_OCTO_REGION", "DEPLOYMENTINFO_REGION":
+		if len(shard.Region) > 0 {
+			return shard.Region
+		}
+	}
+
+	return jitsi.envVarValue(name, variables)
+}
+
+// ShardEnvVars resolves the variables names of a component of the shard,
+// its own variables taking precedence over spec.variables. Those of its own
+// variables names does not list are passed as well.
+func (jitsi *Jitsi) ShardEnvVars(shard *Shard, component *ComponentEnv, names []string) []corev1.EnvVar {
+	var envVars []corev1.EnvVar
+
+	variables := jitsi.componentVariables(component)
+	extra := []string{}
+	for name := range component.Variables {
+		if !slices.Contains(names, name) {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	names = append(names[:len(names):len(names)], extra...)
+
+	for _, name := range names {
+		if value := jitsi.shardEnvVarValue(shard, name, variables); len(value) > 0 {
+			envVars = append(envVars, corev1.EnvVar{
+				Name:  name,
+				Value: value,
+			})
+		}
+	}
+	return envVars
+}
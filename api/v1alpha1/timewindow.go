@@ -0,0 +1,160 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var weekdays = map[Weekday]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// Location returns the time zone of the instance, UTC when spec.timezone is
+// unset. An unknown one fails Validate, the instance is not reconciled then.
+func (jitsi *Jitsi) Location() *time.Location {
+	if len(jitsi.Spec.Timezone) > 0 {
+		if location, err := time.LoadLocation(jitsi.Spec.Timezone); err == nil {
+			return location
+		}
+	}
+	return time.UTC
+}
+
+// parseTimeOfDay returns the minutes since midnight of HH:MM, on the wall
+// clock so that days of a DST change keep their times of day
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *TimeWindow) matchesDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[d] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *TimeWindow) bounds() (int, int, error) {
+	start, err := parseTimeOfDay(w.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseTimeOfDay(w.End)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// Validate checks the window is either a cron schedule with a duration or a
+// time of day range
+func (w *TimeWindow) Validate() error {
+	if len(w.Schedule) > 0 {
+		if w.Duration == nil || w.Duration.Duration <= 0 {
+			return fmt.Errorf("a window with a schedule requires a positive duration")
+		}
+		if _, err := cron.ParseStandard(w.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule %q: %w", w.Schedule, err)
+		}
+		return nil
+	}
+	if len(w.Start) == 0 || len(w.End) == 0 {
+		return fmt.Errorf("a window requires either a schedule or both start and end")
+	}
+	for _, day := range w.Days {
+		if _, ok := weekdays[day]; !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+	}
+	_, _, err := w.bounds()
+	return err
+}
+
+// Active reports whether now falls in the window
+func (w *TimeWindow) Active(now time.Time) (bool, error) {
+	if len(w.Schedule) > 0 {
+		if err := w.Validate(); err != nil {
+			return false, err
+		}
+		schedule, _ := cron.ParseStandard(w.Schedule)
+		return !schedule.Next(now.Add(-w.Duration.Duration)).After(now), nil
+	}
+
+	start, end, err := w.bounds()
+	if err != nil {
+		return false, err
+	}
+	clock := now.Hour()*60 + now.Minute()
+
+	switch {
+	case start < end:
+		return w.matchesDay(now.Weekday()) && clock >= start && clock < end, nil
+	case start == end:
+		return w.matchesDay(now.Weekday()), nil
+	default:
+		yesterday := (now.Weekday() + 6) % 7
+		return (w.matchesDay(now.Weekday()) && clock >= start) || (w.matchesDay(yesterday) && clock < end), nil
+	}
+}
+
+// NextStart returns the next time the window opens after now
+func (w *TimeWindow) NextStart(now time.Time) (time.Time, error) {
+	if len(w.Schedule) > 0 {
+		schedule, err := cron.ParseStandard(w.Schedule)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return schedule.Next(now), nil
+	}
+
+	start, _, err := w.bounds()
+	if err != nil {
+		return time.Time{}, err
+	}
+	for i := 0; i <= 7; i++ {
+		next := time.Date(now.Year(), now.Month(), now.Day()+i, start/60, start%60, 0, 0, now.Location())
+		if next.After(now) && w.matchesDay(next.Weekday()) {
+			return next, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("window never opens")
+}
+
+// ActiveWindow returns the first of windows that is active at now, or nil
+// along with the next time one of them opens
+func ActiveWindow(windows []TimeWindow, now time.Time) (*TimeWindow, time.Time, error) {
+	var next time.Time
+	for i := range windows {
+		active, err := windows[i].Active(now)
+		if err != nil {
+			return nil, next, err
+		}
+		if active {
+			return &windows[i], next, nil
+		}
+		start, err := windows[i].NextStart(now)
+		if err != nil {
+			return nil, next, err
+		}
+		if next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	return nil, next, nil
+}
@@ -0,0 +1,115 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// TURNPort is where the managed coturn listens for TURN
+const TURNPort = 3478
+
+// maxLoadBalancerRelayPorts bounds the relay ports of the LoadBalancer mode,
+// each of them is a port of its Service
+const maxLoadBalancerRelayPorts = 100
+
+// ManagedTURN returns the coturn deployed for the instance, nil when the
+// instance points at an external TURN server
+func (jitsi *Jitsi) ManagedTURN() *ManagedTURN {
+	if jitsi.Spec.TURN == nil {
+		return nil
+	}
+	return jitsi.Spec.TURN.Managed
+}
+
+// RelayPorts returns the first and last UDP ports relaying the media
+func (turn *ManagedTURN) RelayPorts() (int32, int32) {
+	first, last := int32(49160), int32(49200)
+	if turn.MinRelayPort != nil {
+		first = *turn.MinRelayPort
+	}
+	if turn.MaxRelayPort != nil {
+		last = *turn.MaxRelayPort
+	}
+	return first, last
+}
+
+func (turn *TURN) setDefaults() {
+	managed := turn.Managed
+	if managed == nil {
+		return
+	}
+
+	if turn.Port == 0 {
+		turn.Port = TURNPort
+		if turn.TLS {
+			turn.Port = 443
+		}
+	}
+
+	if len(managed.Mode) == 0 {
+		managed.Mode = TURNLoadBalancer
+	}
+
+	if managed.Mode == TURNLoadBalancer && managed.Replicas == nil {
+		defaultReplicas := int32(1)
+		managed.Replicas = &defaultReplicas
+	}
+
+	if managed.PublicIP != nil {
+		managed.PublicIP.setDefaults()
+	}
+
+	if len(managed.TLSSecret) == 0 {
+		managed.TLSSecret = turn.Host + "-tls"
+	}
+
+	if managed.Issuer != nil && len(managed.Issuer.Kind) == 0 {
+		managed.Issuer.Kind = "Issuer"
+	}
+}
+
+func (turn *TURN) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if len(turn.Host) == 0 {
+		errs = append(errs, field.Required(path.Child("host"), ""))
+	}
+	// the port of a managed TURN server is defaulted
+	if (turn.Managed == nil || turn.Port != 0) && (turn.Port < 1 || turn.Port > 65535) {
+		errs = append(errs, field.Invalid(path.Child("port"), turn.Port, "must be between 1 and 65535"))
+	}
+
+	managed := turn.Managed
+	if managed == nil {
+		return errs
+	}
+	if turn.Secret != nil {
+		errs = append(errs, field.Forbidden(path.Child("secret"), "a managed TURN server uses a generated secret"))
+	}
+	if turn.TLS && turn.Port == TURNPort {
+		errs = append(errs, field.Invalid(path.Child("port"), turn.Port, "TURNS needs a port other than the TURN one"))
+	}
+	path = path.Child("managed")
+
+	if managed.Replicas != nil && (*managed.Replicas < 0 || *managed.Replicas > 1) {
+		errs = append(errs, field.Invalid(path.Child("replicas"), *managed.Replicas, "must be 0 or 1, the relay ports of the load balancer reach a single coturn"))
+	}
+	first, last := managed.RelayPorts()
+	if first < 1024 || last > 65535 || first > last {
+		errs = append(errs, field.Invalid(path.Child("minRelayPort"), first, "the relay ports must be an ordered range within 1024-65535"))
+	} else if managed.Mode == TURNLoadBalancer && last-first+1 > maxLoadBalancerRelayPorts {
+		errs = append(errs, field.Invalid(path.Child("maxRelayPort"), last, fmt.Sprintf("the LoadBalancer mode publishes at most %d relay ports", maxLoadBalancerRelayPorts)))
+	}
+	if managed.PublicIP != nil {
+		if managed.Mode != TURNHostNetwork {
+			errs = append(errs, field.Forbidden(path.Child("publicIP"), "coturn relays on the address of its load balancer in the LoadBalancer mode"))
+		}
+		errs = append(errs, managed.PublicIP.validate(path.Child("publicIP"))...)
+	}
+	if managed.Issuer != nil && len(managed.Issuer.Name) == 0 {
+		errs = append(errs, field.Required(path.Child("issuer", "name"), ""))
+	}
+
+	return errs
+}
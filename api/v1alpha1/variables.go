@@ -0,0 +1,122 @@
+package v1alpha1
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/strings/slices"
+)
+
+// specVariables are set from the fields of the spec, whatever the variables
+var specVariables = map[string]string{
+	"TZ":                        "spec.timezone",
+	"XMPP_SERVER":               "the shard",
+	"XMPP_BOSH_URL_BASE":        "the shard",
+	"JVB_PORT":                  "spec.jvb.ports.udp",
+	"JVB_TCP_PORT":              "spec.jvb.ports.tcp",
+	"DEPLOYMENTINFO_USERREGION": "spec.region",
+	"DEPLOYMENTINFO_REGION":     "spec.region",
+	"JVB_OCTO_REGION":           "spec.region",
+	"JICOFO_OCTO_REGION":        "spec.region",
+	"PUBLIC_URL":                "spec.domain",
+}
+
+// variableSetBy returns the field of the spec the variable is set from, if
+// any
+func (jitsi *Jitsi) variableSetBy(name string) string {
+	if jitsi.Spec.Auth != nil {
+		if _, ok := jitsi.Spec.Auth.envVarValue(name); ok {
+			return "spec.auth"
+		}
+	}
+	if jitsi.Spec.Prosody.Config != nil {
+		if _, ok := jitsi.Spec.Prosody.Config.envVarValue(name); ok {
+			return "spec.prosody.config"
+		}
+	}
+	if name == "JICOFO_BRIDGE_REGION_GROUPS" && jitsi.Spec.Federation != nil && len(jitsi.Spec.Federation.RegionGroups) > 0 {
+		return "spec.federation.regionGroups"
+	}
+	return specVariables[name]
+}
+
+func sortedNames(variables map[string]string) []string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isSecretVariable(name string) bool {
+	for _, secret := range secretVariables {
+		if secret[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// variablesWarnings reports the variables that are ignored or unknown. The
+// variables are resolved, by increasing precedence, from the defaults,
+// spec.variables, the variables of the component, the fields of the spec and
+// the env of the component.
+func (jitsi *Jitsi) variablesWarnings() []string {
+	warnings := []string{}
+
+	components := []struct {
+		name      string
+		env       *ComponentEnv
+		variables []string
+	}{
+		{"prosody", &jitsi.Spec.Prosody.ComponentEnv, ProsodyVariables},
+		{"jicofo", &jitsi.Spec.Jicofo.ComponentEnv, JicofoVariables},
+		{"jvb", &jitsi.Spec.JVB.ComponentEnv, JvbVariables},
+		{"jibri", &jitsi.Spec.Jibri.ComponentEnv, JibriVariables},
+		{"web", &jitsi.Spec.Web.ComponentEnv, WebVariables},
+	}
+
+	for _, name := range sortedNames(jitsi.Spec.Variables) {
+		if isSecretVariable(name) {
+			continue
+		}
+		if field := jitsi.variableSetBy(name); len(field) > 0 {
+			warnings = append(warnings, "spec.variables."+name+" is overridden by "+field)
+			continue
+		}
+		known := false
+		for _, component := range components {
+			known = known || slices.Contains(component.variables, name)
+		}
+		if !known {
+			warnings = append(warnings, "spec.variables."+name+" is not read by any component, set it in the variables of a component to pass it anyway")
+		}
+	}
+
+	for _, component := range components {
+		path := "spec." + component.name + ".variables."
+		for _, name := range sortedNames(component.env.Variables) {
+			switch {
+			case isSecretVariable(name):
+				warnings = append(warnings, path+name+" holds a secret in a plain value, set it through "+component.name+".env from a Secret")
+			case len(jitsi.variableSetBy(name)) > 0:
+				warnings = append(warnings, path+name+" is overridden by "+jitsi.variableSetBy(name)+", set it through spec."+component.name+".env to force it")
+			case !slices.Contains(component.variables, name):
+				warnings = append(warnings, path+name+" is not a known variable of "+component.name+", it is passed as is")
+			}
+		}
+	}
+
+	return warnings
+}
+
+func (env *ComponentEnv) validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, envVar := range env.Env {
+		if len(envVar.Name) == 0 {
+			errs = append(errs, field.Required(path.Child("env").Index(i).Child("name"), ""))
+		}
+	}
+	return errs
+}
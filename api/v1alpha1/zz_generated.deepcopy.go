@@ -22,8 +22,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/autoscaling/v2"
 	"k8s.io/api/core/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -42,6 +44,31 @@ func (in *AffinitySettings) DeepCopy() *AffinitySettings {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Auth) DeepCopyInto(out *Auth) {
+	*out = *in
+	if in.JWT != nil {
+		in, out := &in.JWT, &out.JWT
+		*out = new(JWTAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LDAP != nil {
+		in, out := &in.LDAP, &out.LDAP
+		*out = new(LDAPAuth)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Auth.
+func (in *Auth) DeepCopy() *Auth {
+	if in == nil {
+		return nil
+	}
+	out := new(Auth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BucketSettings) DeepCopyInto(out *BucketSettings) {
 	*out = *in
@@ -62,6 +89,105 @@ func (in *BucketSettings) DeepCopy() *BucketSettings {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacitySchedule) DeepCopyInto(out *CapacitySchedule) {
+	*out = *in
+	in.TimeWindow.DeepCopyInto(&out.TimeWindow)
+	if in.Lead != nil {
+		in, out := &in.Lead, &out.Lead
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.JVB != nil {
+		in, out := &in.JVB, &out.JVB
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Web != nil {
+		in, out := &in.Web, &out.Web
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Jibri != nil {
+		in, out := &in.Jibri, &out.Jibri
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacitySchedule.
+func (in *CapacitySchedule) DeepCopy() *CapacitySchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacitySchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentEnv) DeepCopyInto(out *ComponentEnv) {
+	*out = *in
+	if in.Variables != nil {
+		in, out := &in.Variables, &out.Variables
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentEnv.
+func (in *ComponentEnv) DeepCopy() *ComponentEnv {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentEnv)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentStatus) DeepCopyInto(out *ComponentStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentStatus.
+func (in *ComponentStatus) DeepCopy() *ComponentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConferenceStats) DeepCopyInto(out *ConferenceStats) {
+	*out = *in
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConferenceStats.
+func (in *ConferenceStats) DeepCopy() *ConferenceStats {
+	if in == nil {
+		return nil
+	}
+	out := new(ConferenceStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ContainerRuntime) DeepCopyInto(out *ContainerRuntime) {
 	*out = *in
@@ -83,22 +209,44 @@ func (in *ContainerRuntime) DeepCopy() *ContainerRuntime {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Image) DeepCopyInto(out *Image) {
+func (in *Federation) DeepCopyInto(out *Federation) {
 	*out = *in
+	if in.Expose != nil {
+		in, out := &in.Expose, &out.Expose
+		*out = new(FederationExpose)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RegionGroups != nil {
+		in, out := &in.RegionGroups, &out.RegionGroups
+		*out = make([]RegionGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Upstream != nil {
+		in, out := &in.Upstream, &out.Upstream
+		*out = new(Upstream)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Image.
-func (in *Image) DeepCopy() *Image {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Federation.
+func (in *Federation) DeepCopy() *Federation {
 	if in == nil {
 		return nil
 	}
-	out := new(Image)
+	out := new(Federation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Ingress) DeepCopyInto(out *Ingress) {
+func (in *FederationExpose) DeepCopyInto(out *FederationExpose) {
 	*out = *in
 	if in.Annotations != nil {
 		in, out := &in.Annotations, &out.Annotations
@@ -109,86 +257,95 @@ func (in *Ingress) DeepCopyInto(out *Ingress) {
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ingress.
-func (in *Ingress) DeepCopy() *Ingress {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationExpose.
+func (in *FederationExpose) DeepCopy() *FederationExpose {
 	if in == nil {
 		return nil
 	}
-	out := new(Ingress)
+	out := new(FederationExpose)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JVB) DeepCopyInto(out *JVB) {
+func (in *ICEServer) DeepCopyInto(out *ICEServer) {
 	*out = *in
-	if in.ContainerRuntime != nil {
-		in, out := &in.ContainerRuntime, &out.ContainerRuntime
-		*out = new(ContainerRuntime)
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(v1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
-	in.AffinitySettings.DeepCopyInto(&out.AffinitySettings)
-	in.Strategy.DeepCopyInto(&out.Strategy)
-	in.Ports.DeepCopyInto(&out.Ports)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVB.
-func (in *JVB) DeepCopy() *JVB {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ICEServer.
+func (in *ICEServer) DeepCopy() *ICEServer {
 	if in == nil {
 		return nil
 	}
-	out := new(JVB)
+	out := new(ICEServer)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JVBPorts) DeepCopyInto(out *JVBPorts) {
+func (in *Image) DeepCopyInto(out *Image) {
 	*out = *in
-	if in.UDP != nil {
-		in, out := &in.UDP, &out.UDP
-		*out = new(int32)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Image.
+func (in *Image) DeepCopy() *Image {
+	if in == nil {
+		return nil
 	}
-	if in.TCP != nil {
-		in, out := &in.TCP, &out.TCP
-		*out = new(int32)
-		**out = **in
+	out := new(Image)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Ingress) DeepCopyInto(out *Ingress) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBPorts.
-func (in *JVBPorts) DeepCopy() *JVBPorts {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ingress.
+func (in *Ingress) DeepCopy() *Ingress {
 	if in == nil {
 		return nil
 	}
-	out := new(JVBPorts)
+	out := new(Ingress)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JVBStrategy) DeepCopyInto(out *JVBStrategy) {
+func (in *IssuerReference) DeepCopyInto(out *IssuerReference) {
 	*out = *in
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
-		*out = new(int32)
-		**out = **in
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBStrategy.
-func (in *JVBStrategy) DeepCopy() *JVBStrategy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IssuerReference.
+func (in *IssuerReference) DeepCopy() *IssuerReference {
 	if in == nil {
 		return nil
 	}
-	out := new(JVBStrategy)
+	out := new(IssuerReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Jibri) DeepCopyInto(out *Jibri) {
+func (in *JVB) DeepCopyInto(out *JVB) {
 	*out = *in
 	if in.ContainerRuntime != nil {
 		in, out := &in.ContainerRuntime, &out.ContainerRuntime
@@ -196,70 +353,110 @@ func (in *Jibri) DeepCopyInto(out *Jibri) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.AffinitySettings.DeepCopyInto(&out.AffinitySettings)
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
-		*out = new(int32)
-		**out = **in
+	in.PodOverrides.DeepCopyInto(&out.PodOverrides)
+	in.ComponentEnv.DeepCopyInto(&out.ComponentEnv)
+	in.Strategy.DeepCopyInto(&out.Strategy)
+	in.Ports.DeepCopyInto(&out.Ports)
+	if in.PublicIP != nil {
+		in, out := &in.PublicIP, &out.PublicIP
+		*out = new(PublicIP)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.Bucket != nil {
-		in, out := &in.Bucket, &out.Bucket
-		*out = new(BucketSettings)
+	if in.Exposure != nil {
+		in, out := &in.Exposure, &out.Exposure
+		*out = new(JVBExposure)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TCP != nil {
+		in, out := &in.TCP, &out.TCP
+		*out = new(JVBTCP)
 		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Jibri.
-func (in *Jibri) DeepCopy() *Jibri {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVB.
+func (in *JVB) DeepCopy() *JVB {
 	if in == nil {
 		return nil
 	}
-	out := new(Jibri)
+	out := new(JVB)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Jicofo) DeepCopyInto(out *Jicofo) {
+func (in *JVBAutoscaler) DeepCopyInto(out *JVBAutoscaler) {
 	*out = *in
-	if in.ContainerRuntime != nil {
-		in, out := &in.ContainerRuntime, &out.ContainerRuntime
-		*out = new(ContainerRuntime)
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Behavior != nil {
+		in, out := &in.Behavior, &out.Behavior
+		*out = new(v2.HorizontalPodAutoscalerBehavior)
 		(*in).DeepCopyInto(*out)
 	}
-	in.AffinitySettings.DeepCopyInto(&out.AffinitySettings)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Jicofo.
-func (in *Jicofo) DeepCopy() *Jicofo {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBAutoscaler.
+func (in *JVBAutoscaler) DeepCopy() *JVBAutoscaler {
 	if in == nil {
 		return nil
 	}
-	out := new(Jicofo)
+	out := new(JVBAutoscaler)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Jitsi) DeepCopyInto(out *Jitsi) {
+func (in *JVBExposure) DeepCopyInto(out *JVBExposure) {
+	*out = *in
+	if in.PortRange != nil {
+		in, out := &in.PortRange, &out.PortRange
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBExposure.
+func (in *JVBExposure) DeepCopy() *JVBExposure {
+	if in == nil {
+		return nil
+	}
+	out := new(JVBExposure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JVBPool) DeepCopyInto(out *JVBPool) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Jitsi.
-func (in *Jitsi) DeepCopy() *Jitsi {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBPool.
+func (in *JVBPool) DeepCopy() *JVBPool {
 	if in == nil {
 		return nil
 	}
-	out := new(Jitsi)
+	out := new(JVBPool)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Jitsi) DeepCopyObject() runtime.Object {
+func (in *JVBPool) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -267,31 +464,31 @@ func (in *Jitsi) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JitsiList) DeepCopyInto(out *JitsiList) {
+func (in *JVBPoolList) DeepCopyInto(out *JVBPoolList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]Jitsi, len(*in))
+		*out = make([]JVBPool, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiList.
-func (in *JitsiList) DeepCopy() *JitsiList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBPoolList.
+func (in *JVBPoolList) DeepCopy() *JVBPoolList {
 	if in == nil {
 		return nil
 	}
-	out := new(JitsiList)
+	out := new(JVBPoolList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *JitsiList) DeepCopyObject() runtime.Object {
+func (in *JVBPoolList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -299,76 +496,1054 @@ func (in *JitsiList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JitsiSpec) DeepCopyInto(out *JitsiSpec) {
+func (in *JVBPoolSpec) DeepCopyInto(out *JVBPoolSpec) {
 	*out = *in
+	out.JitsiRef = in.JitsiRef
 	in.JVB.DeepCopyInto(&out.JVB)
-	in.Prosody.DeepCopyInto(&out.Prosody)
-	in.Jicofo.DeepCopyInto(&out.Jicofo)
-	in.Jibri.DeepCopyInto(&out.Jibri)
-	in.Web.DeepCopyInto(&out.Web)
-	out.Image = in.Image
-	if in.Variables != nil {
-		in, out := &in.Variables, &out.Variables
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	in.Ingress.DeepCopyInto(&out.Ingress)
-	if in.TURN != nil {
-		in, out := &in.TURN, &out.TURN
-		*out = new(TURN)
-		(*in).DeepCopyInto(*out)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiSpec.
-func (in *JitsiSpec) DeepCopy() *JitsiSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBPoolSpec.
+func (in *JVBPoolSpec) DeepCopy() *JVBPoolSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(JitsiSpec)
+	out := new(JVBPoolSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JitsiStatus) DeepCopyInto(out *JitsiStatus) {
+func (in *JVBPoolStatus) DeepCopyInto(out *JVBPoolStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiStatus.
-func (in *JitsiStatus) DeepCopy() *JitsiStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBPoolStatus.
+func (in *JVBPoolStatus) DeepCopy() *JVBPoolStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(JitsiStatus)
+	out := new(JVBPoolStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Prosody) DeepCopyInto(out *Prosody) {
+func (in *JVBPorts) DeepCopyInto(out *JVBPorts) {
 	*out = *in
-	if in.ContainerRuntime != nil {
+	if in.UDP != nil {
+		in, out := &in.UDP, &out.UDP
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TCP != nil {
+		in, out := &in.TCP, &out.TCP
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBPorts.
+func (in *JVBPorts) DeepCopy() *JVBPorts {
+	if in == nil {
+		return nil
+	}
+	out := new(JVBPorts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JVBStrategy) DeepCopyInto(out *JVBStrategy) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Autoscaler != nil {
+		in, out := &in.Autoscaler, &out.Autoscaler
+		*out = new(JVBAutoscaler)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBStrategy.
+func (in *JVBStrategy) DeepCopy() *JVBStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(JVBStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JVBTCP) DeepCopyInto(out *JVBTCP) {
+	*out = *in
+	if in.AdvertisedPort != nil {
+		in, out := &in.AdvertisedPort, &out.AdvertisedPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SSLTCP != nil {
+		in, out := &in.SSLTCP, &out.SSLTCP
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVBTCP.
+func (in *JVBTCP) DeepCopy() *JVBTCP {
+	if in == nil {
+		return nil
+	}
+	out := new(JVBTCP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTAuth) DeepCopyInto(out *JWTAuth) {
+	*out = *in
+	if in.AppSecret != nil {
+		in, out := &in.AppSecret, &out.AppSecret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AcceptedIssuers != nil {
+		in, out := &in.AcceptedIssuers, &out.AcceptedIssuers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AcceptedAudiences != nil {
+		in, out := &in.AcceptedAudiences, &out.AcceptedAudiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTAuth.
+func (in *JWTAuth) DeepCopy() *JWTAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Jibri) DeepCopyInto(out *Jibri) {
+	*out = *in
+	if in.ContainerRuntime != nil {
+		in, out := &in.ContainerRuntime, &out.ContainerRuntime
+		*out = new(ContainerRuntime)
+		(*in).DeepCopyInto(*out)
+	}
+	in.AffinitySettings.DeepCopyInto(&out.AffinitySettings)
+	in.PodOverrides.DeepCopyInto(&out.PodOverrides)
+	in.ComponentEnv.DeepCopyInto(&out.ComponentEnv)
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Bucket != nil {
+		in, out := &in.Bucket, &out.Bucket
+		*out = new(BucketSettings)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Jibri.
+func (in *Jibri) DeepCopy() *Jibri {
+	if in == nil {
+		return nil
+	}
+	out := new(Jibri)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Jicofo) DeepCopyInto(out *Jicofo) {
+	*out = *in
+	if in.ContainerRuntime != nil {
+		in, out := &in.ContainerRuntime, &out.ContainerRuntime
+		*out = new(ContainerRuntime)
+		(*in).DeepCopyInto(*out)
+	}
+	in.AffinitySettings.DeepCopyInto(&out.AffinitySettings)
+	in.PodOverrides.DeepCopyInto(&out.PodOverrides)
+	in.ComponentEnv.DeepCopyInto(&out.ComponentEnv)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Jicofo.
+func (in *Jicofo) DeepCopy() *Jicofo {
+	if in == nil {
+		return nil
+	}
+	out := new(Jicofo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Jitsi) DeepCopyInto(out *Jitsi) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Jitsi.
+func (in *Jitsi) DeepCopy() *Jitsi {
+	if in == nil {
+		return nil
+	}
+	out := new(Jitsi)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Jitsi) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JitsiList) DeepCopyInto(out *JitsiList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Jitsi, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiList.
+func (in *JitsiList) DeepCopy() *JitsiList {
+	if in == nil {
+		return nil
+	}
+	out := new(JitsiList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JitsiList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JitsiReference) DeepCopyInto(out *JitsiReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiReference.
+func (in *JitsiReference) DeepCopy() *JitsiReference {
+	if in == nil {
+		return nil
+	}
+	out := new(JitsiReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JitsiSpec) DeepCopyInto(out *JitsiSpec) {
+	*out = *in
+	in.JVB.DeepCopyInto(&out.JVB)
+	in.Prosody.DeepCopyInto(&out.Prosody)
+	in.Jicofo.DeepCopyInto(&out.Jicofo)
+	in.Jibri.DeepCopyInto(&out.Jibri)
+	in.Web.DeepCopyInto(&out.Web)
+	out.Image = in.Image
+	if in.Variables != nil {
+		in, out := &in.Variables, &out.Variables
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Ingress.DeepCopyInto(&out.Ingress)
+	if in.TURN != nil {
+		in, out := &in.TURN, &out.TURN
+		*out = new(TURN)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ICEServers != nil {
+		in, out := &in.ICEServers, &out.ICEServers
+		*out = make([]ICEServer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = new(Secrets)
+		**out = **in
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(Auth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(UpgradeStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Teardown != nil {
+		in, out := &in.Teardown, &out.Teardown
+		*out = new(Teardown)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Shards != nil {
+		in, out := &in.Shards, &out.Shards
+		*out = new(Sharding)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Federation != nil {
+		in, out := &in.Federation, &out.Federation
+		*out = new(Federation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CapacitySchedules != nil {
+		in, out := &in.CapacitySchedules, &out.CapacitySchedules
+		*out = make([]CapacitySchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiSpec.
+func (in *JitsiSpec) DeepCopy() *JitsiSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JitsiSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JitsiStatus) DeepCopyInto(out *JitsiStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Prosody != nil {
+		in, out := &in.Prosody, &out.Prosody
+		*out = new(ComponentStatus)
+		**out = **in
+	}
+	if in.Jicofo != nil {
+		in, out := &in.Jicofo, &out.Jicofo
+		*out = new(ComponentStatus)
+		**out = **in
+	}
+	if in.JVB != nil {
+		in, out := &in.JVB, &out.JVB
+		*out = new(ComponentStatus)
+		**out = **in
+	}
+	if in.Web != nil {
+		in, out := &in.Web, &out.Web
+		*out = new(ComponentStatus)
+		**out = **in
+	}
+	if in.Jibri != nil {
+		in, out := &in.Jibri, &out.Jibri
+		*out = new(ComponentStatus)
+		**out = **in
+	}
+	if in.Stats != nil {
+		in, out := &in.Stats, &out.Stats
+		*out = new(ConferenceStats)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(UpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = new(SecretsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContentVersions != nil {
+		in, out := &in.ContentVersions, &out.ContentVersions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Upstream != nil {
+		in, out := &in.Upstream, &out.Upstream
+		*out = new(UpstreamStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiStatus.
+func (in *JitsiStatus) DeepCopy() *JitsiStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JitsiStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JitsiUser) DeepCopyInto(out *JitsiUser) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiUser.
+func (in *JitsiUser) DeepCopy() *JitsiUser {
+	if in == nil {
+		return nil
+	}
+	out := new(JitsiUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JitsiUser) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JitsiUserList) DeepCopyInto(out *JitsiUserList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]JitsiUser, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiUserList.
+func (in *JitsiUserList) DeepCopy() *JitsiUserList {
+	if in == nil {
+		return nil
+	}
+	out := new(JitsiUserList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JitsiUserList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JitsiUserSpec) DeepCopyInto(out *JitsiUserSpec) {
+	*out = *in
+	out.JitsiRef = in.JitsiRef
+	in.Password.DeepCopyInto(&out.Password)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiUserSpec.
+func (in *JitsiUserSpec) DeepCopy() *JitsiUserSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JitsiUserSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JitsiUserStatus) DeepCopyInto(out *JitsiUserStatus) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JitsiUserStatus.
+func (in *JitsiUserStatus) DeepCopy() *JitsiUserStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JitsiUserStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPAuth) DeepCopyInto(out *LDAPAuth) {
+	*out = *in
+	if in.BindSecret != nil {
+		in, out := &in.BindSecret, &out.BindSecret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPAuth.
+func (in *LDAPAuth) DeepCopy() *LDAPAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedTURN) DeepCopyInto(out *ManagedTURN) {
+	*out = *in
+	if in.ContainerRuntime != nil {
+		in, out := &in.ContainerRuntime, &out.ContainerRuntime
+		*out = new(ContainerRuntime)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PublicIP != nil {
+		in, out := &in.PublicIP, &out.PublicIP
+		*out = new(PublicIP)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MinRelayPort != nil {
+		in, out := &in.MinRelayPort, &out.MinRelayPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxRelayPort != nil {
+		in, out := &in.MaxRelayPort, &out.MaxRelayPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Issuer != nil {
+		in, out := &in.Issuer, &out.Issuer
+		*out = new(IssuerReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedTURN.
+func (in *ManagedTURN) DeepCopy() *ManagedTURN {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedTURN)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodOverrides) DeepCopyInto(out *PodOverrides) {
+	*out = *in
+	if in.PodLabels != nil {
+		in, out := &in.PodLabels, &out.PodLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PodAnnotations != nil {
+		in, out := &in.PodAnnotations, &out.PodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnvFrom != nil {
+		in, out := &in.EnvFrom, &out.EnvFrom
+		*out = make([]v1.EnvFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraVolumes != nil {
+		in, out := &in.ExtraVolumes, &out.ExtraVolumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraVolumeMounts != nil {
+		in, out := &in.ExtraVolumeMounts, &out.ExtraVolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Sidecars != nil {
+		in, out := &in.Sidecars, &out.Sidecars
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraInitContainers != nil {
+		in, out := &in.ExtraInitContainers, &out.ExtraInitContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Patches != nil {
+		in, out := &in.Patches, &out.Patches
+		*out = make([]PodPatch, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodOverrides.
+func (in *PodOverrides) DeepCopy() *PodOverrides {
+	if in == nil {
+		return nil
+	}
+	out := new(PodOverrides)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodPatch) DeepCopyInto(out *PodPatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodPatch.
+func (in *PodPatch) DeepCopy() *PodPatch {
+	if in == nil {
+		return nil
+	}
+	out := new(PodPatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Prosody) DeepCopyInto(out *Prosody) {
+	*out = *in
+	if in.ContainerRuntime != nil {
+		in, out := &in.ContainerRuntime, &out.ContainerRuntime
+		*out = new(ContainerRuntime)
+		(*in).DeepCopyInto(*out)
+	}
+	in.AffinitySettings.DeepCopyInto(&out.AffinitySettings)
+	in.PodOverrides.DeepCopyInto(&out.PodOverrides)
+	in.ComponentEnv.DeepCopyInto(&out.ComponentEnv)
+	if in.CustomProsodyConfig != nil {
+		in, out := &in.CustomProsodyConfig, &out.CustomProsodyConfig
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(ProsodyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Plugins != nil {
+		in, out := &in.Plugins, &out.Plugins
+		*out = make([]ProsodyPlugin, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Snippets != nil {
+		in, out := &in.Snippets, &out.Snippets
+		*out = make([]ProsodySnippet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Prosody.
+func (in *Prosody) DeepCopy() *Prosody {
+	if in == nil {
+		return nil
+	}
+	out := new(Prosody)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProsodyConfig) DeepCopyInto(out *ProsodyConfig) {
+	*out = *in
+	if in.Modules != nil {
+		in, out := &in.Modules, &out.Modules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MUCModules != nil {
+		in, out := &in.MUCModules, &out.MUCModules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GlobalModules != nil {
+		in, out := &in.GlobalModules, &out.GlobalModules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Lobby != nil {
+		in, out := &in.Lobby, &out.Lobby
+		*out = new(bool)
+		**out = **in
+	}
+	if in.BreakoutRooms != nil {
+		in, out := &in.BreakoutRooms, &out.BreakoutRooms
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Polls != nil {
+		in, out := &in.Polls, &out.Polls
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaxParticipants != nil {
+		in, out := &in.MaxParticipants, &out.MaxParticipants
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProsodyConfig.
+func (in *ProsodyConfig) DeepCopy() *ProsodyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProsodyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProsodyPlugin) DeepCopyInto(out *ProsodyPlugin) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProsodyPlugin.
+func (in *ProsodyPlugin) DeepCopy() *ProsodyPlugin {
+	if in == nil {
+		return nil
+	}
+	out := new(ProsodyPlugin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProsodySnippet) DeepCopyInto(out *ProsodySnippet) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProsodySnippet.
+func (in *ProsodySnippet) DeepCopy() *ProsodySnippet {
+	if in == nil {
+		return nil
+	}
+	out := new(ProsodySnippet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PublicIP) DeepCopyInto(out *PublicIP) {
+	*out = *in
+	if in.MetadataHeaders != nil {
+		in, out := &in.MetadataHeaders, &out.MetadataHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PublicIP.
+func (in *PublicIP) DeepCopy() *PublicIP {
+	if in == nil {
+		return nil
+	}
+	out := new(PublicIP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *References) DeepCopyInto(out *References) {
+	*out = *in
+	if in.ConfigMaps != nil {
+		in, out := &in.ConfigMaps, &out.ConfigMaps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new References.
+func (in *References) DeepCopy() *References {
+	if in == nil {
+		return nil
+	}
+	out := new(References)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegionGroup) DeepCopyInto(out *RegionGroup) {
+	*out = *in
+	if in.Regions != nil {
+		in, out := &in.Regions, &out.Regions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegionGroup.
+func (in *RegionGroup) DeepCopy() *RegionGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(RegionGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Router) DeepCopyInto(out *Router) {
+	*out = *in
+	if in.ContainerRuntime != nil {
 		in, out := &in.ContainerRuntime, &out.ContainerRuntime
 		*out = new(ContainerRuntime)
 		(*in).DeepCopyInto(*out)
 	}
 	in.AffinitySettings.DeepCopyInto(&out.AffinitySettings)
-	if in.CustomProsodyConfig != nil {
-		in, out := &in.CustomProsodyConfig, &out.CustomProsodyConfig
-		*out = new(v1.LocalObjectReference)
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Prosody.
-func (in *Prosody) DeepCopy() *Prosody {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Router.
+func (in *Router) DeepCopy() *Router {
 	if in == nil {
 		return nil
 	}
-	out := new(Prosody)
+	out := new(Router)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Secrets) DeepCopyInto(out *Secrets) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Secrets.
+func (in *Secrets) DeepCopy() *Secrets {
+	if in == nil {
+		return nil
+	}
+	out := new(Secrets)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretsStatus) DeepCopyInto(out *SecretsStatus) {
+	*out = *in
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PendingSince != nil {
+		in, out := &in.PendingSince, &out.PendingSince
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRotationTime != nil {
+		in, out := &in.LastRotationTime, &out.LastRotationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretsStatus.
+func (in *SecretsStatus) DeepCopy() *SecretsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Shard) DeepCopyInto(out *Shard) {
+	*out = *in
+	if in.JVBStrategy != nil {
+		in, out := &in.JVBStrategy, &out.JVBStrategy
+		*out = new(JVBStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Shard.
+func (in *Shard) DeepCopy() *Shard {
+	if in == nil {
+		return nil
+	}
+	out := new(Shard)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Sharding) DeepCopyInto(out *Sharding) {
+	*out = *in
+	if in.List != nil {
+		in, out := &in.List, &out.List
+		*out = make([]Shard, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Router.DeepCopyInto(&out.Router)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Sharding.
+func (in *Sharding) DeepCopy() *Sharding {
+	if in == nil {
+		return nil
+	}
+	out := new(Sharding)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -381,6 +1556,11 @@ func (in *TURN) DeepCopyInto(out *TURN) {
 		*out = new(v1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Managed != nil {
+		in, out := &in.Managed, &out.Managed
+		*out = new(ManagedTURN)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TURN.
@@ -393,6 +1573,147 @@ func (in *TURN) DeepCopy() *TURN {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Teardown) DeepCopyInto(out *Teardown) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Teardown.
+func (in *Teardown) DeepCopy() *Teardown {
+	if in == nil {
+		return nil
+	}
+	out := new(Teardown)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeWindow) DeepCopyInto(out *TimeWindow) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]Weekday, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeWindow.
+func (in *TimeWindow) DeepCopy() *TimeWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeStatus) DeepCopyInto(out *UpgradeStatus) {
+	*out = *in
+	if in.PendingSince != nil {
+		in, out := &in.PendingSince, &out.PendingSince
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeStatus.
+func (in *UpgradeStatus) DeepCopy() *UpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeStrategy) DeepCopyInto(out *UpgradeStrategy) {
+	*out = *in
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]TimeWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaxWait != nil {
+		in, out := &in.MaxWait, &out.MaxWait
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeStrategy.
+func (in *UpgradeStrategy) DeepCopy() *UpgradeStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Upstream) DeepCopyInto(out *Upstream) {
+	*out = *in
+	if in.JitsiRef != nil {
+		in, out := &in.JitsiRef, &out.JitsiRef
+		*out = new(JitsiReference)
+		**out = **in
+	}
+	if in.Servers != nil {
+		in, out := &in.Servers, &out.Servers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Upstream.
+func (in *Upstream) DeepCopy() *Upstream {
+	if in == nil {
+		return nil
+	}
+	out := new(Upstream)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpstreamStatus) DeepCopyInto(out *UpstreamStatus) {
+	*out = *in
+	if in.Servers != nil {
+		in, out := &in.Servers, &out.Servers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpstreamStatus.
+func (in *UpstreamStatus) DeepCopy() *UpstreamStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpstreamStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Web) DeepCopyInto(out *Web) {
 	*out = *in
@@ -402,6 +1723,8 @@ func (in *Web) DeepCopyInto(out *Web) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.AffinitySettings.DeepCopyInto(&out.AffinitySettings)
+	in.PodOverrides.DeepCopyInto(&out.PodOverrides)
+	in.ComponentEnv.DeepCopyInto(&out.ComponentEnv)
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(int32)
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// authEnvVars are the secrets of spec.auth Prosody reads from its
+// environment, referenced rather than copied into the Deployment. Those
+// still set in spec.variables are passed as they are.
+func authEnvVars(jitsi *v1alpha1.Jitsi) []corev1.EnvVar {
+	envVars := []corev1.EnvVar{}
+	deprecated := jitsi.DeprecatedSecretVariables()
+	for _, name := range []string{"JWT_APP_SECRET", "LDAP_BINDDN", "LDAP_BINDPW"} {
+		if value, ok := deprecated[name]; ok {
+			envVars = append(envVars, corev1.EnvVar{Name: name, Value: value})
+		}
+	}
+
+	auth := jitsi.Spec.Auth
+	if auth == nil {
+		return envVars
+	}
+
+	if auth.Type == v1alpha1.AuthJWT && auth.JWT != nil && auth.JWT.AppSecret != nil {
+		envVars = append(envVars, corev1.EnvVar{
+			Name: "JWT_APP_SECRET",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: auth.JWT.AppSecret,
+			},
+		})
+	}
+	if auth.Type == v1alpha1.AuthLDAP && auth.LDAP != nil && auth.LDAP.BindSecret != nil {
+		envVars = append(envVars, corev1.EnvVar{
+			Name: "LDAP_BINDDN",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: *auth.LDAP.BindSecret,
+					Key:                  "dn",
+				},
+			},
+		}, corev1.EnvVar{
+			Name: "LDAP_BINDPW",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: *auth.LDAP.BindSecret,
+					Key:                  "password",
+				},
+			},
+		})
+	}
+
+	return envVars
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var scaledObjectGVK = schema.GroupVersionKind{Group: "keda.sh", Version: "v1alpha1", Kind: "ScaledObject"}
+
+// jvbMetric tells where each backend reads a metric of the bridges
+type jvbMetric struct {
+	// name of the pods metric served by the metrics adapter
+	adapterName string
+	// key of the metric in the colibri stats of the bridges
+	statsKey string
+	// Prometheus metric exported by the bridges
+	prometheusName string
+}
+
+var jvbMetrics = map[v1alpha1.JVBAutoscalerMetric]jvbMetric{
+	v1alpha1.JVBMetricStress:       {"jitsi-stress-level", "stress_level", "jitsi_jvb_stress"},
+	v1alpha1.JVBMetricParticipants: {"jitsi-participants", "participants", "jitsi_jvb_participants"},
+	v1alpha1.JVBMetricBitrate:      {"jitsi-bitrate", "bit_rate_upload", "jitsi_jvb_bit_rate_upload"},
+}
+
+// jvbMetricLabels are the labels of the bridges the PodMonitor copies onto
+// their metrics, they tell the bridges of each workload apart
+var jvbMetricLabels = []string{
+	"app.kubernetes.io/instance",
+	"app.kubernetes.io/component",
+	v1alpha1.ShardLabel,
+	v1alpha1.PoolLabel,
+}
+
+// prometheusLabels returns the labels of the metrics of the bridges labeled
+// pod, named the way Prometheus sanitizes the pod labels
+func prometheusLabels(pod labels.Set) map[string]string {
+	l := map[string]string{}
+	for _, key := range jvbMetricLabels {
+		if value, ok := pod[key]; ok {
+			l[strings.NewReplacer(".", "_", "/", "_", "-", "_").Replace(key)] = value
+		}
+	}
+	return l
+}
+
+func prometheusMetricName(autoscaler v1alpha1.JVBAutoscaler) string {
+	if len(autoscaler.MetricName) > 0 {
+		return autoscaler.MetricName
+	}
+	return jvbMetrics[autoscaler.Metric].prometheusName
+}
+
+// jvbHPAAnnotations configure the metrics adapter to read the metric of the
+// bridges from their colibri stats
+func jvbHPAAnnotations(strategy v1alpha1.JVBStrategy) map[string]string {
+	autoscaler := strategy.AutoscalerSettings()
+	if autoscaler.Backend != v1alpha1.JVBAutoscalerMetricsAdapter {
+		return nil
+	}
+
+	metric := jvbMetrics[autoscaler.Metric]
+	prefix := fmt.Sprintf("metric-config.pods.%s.json-path", metric.adapterName)
+	return map[string]string{
+		prefix + "/json-key": "$." + metric.statsKey,
+		prefix + "/path":     "/colibri/stats",
+		prefix + "/port":     "8080",
+	}
+}
+
+// jvbHPASpec scales the bridges labeled pod of the Deployment named target
+func jvbHPASpec(target string, pod labels.Set, strategy v1alpha1.JVBStrategy) autoscalingv2.HorizontalPodAutoscalerSpec {
+	autoscaler := strategy.AutoscalerSettings()
+
+	spec := autoscalingv2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       target,
+		},
+		MinReplicas: strategy.Replicas,
+		MaxReplicas: strategy.MaxReplicas,
+		Behavior:    autoscaler.Behavior,
+	}
+
+	averageValue := autoscalingv2.MetricTarget{
+		Type:         autoscalingv2.AverageValueMetricType,
+		AverageValue: autoscaler.Target,
+	}
+
+	if autoscaler.Backend == v1alpha1.JVBAutoscalerPrometheusAdapter {
+		spec.Metrics = []autoscalingv2.MetricSpec{
+			{
+				Type: autoscalingv2.ExternalMetricSourceType,
+				External: &autoscalingv2.ExternalMetricSource{
+					Metric: autoscalingv2.MetricIdentifier{
+						Name: prometheusMetricName(autoscaler),
+						Selector: &metav1.LabelSelector{
+							MatchLabels: prometheusLabels(pod),
+						},
+					},
+					Target: averageValue,
+				},
+			},
+		}
+	} else {
+		spec.Metrics = []autoscalingv2.MetricSpec{
+			{
+				Type: autoscalingv2.PodsMetricSourceType,
+				Pods: &autoscalingv2.PodsMetricSource{
+					Metric: autoscalingv2.MetricIdentifier{
+						Name: jvbMetrics[autoscaler.Metric].adapterName,
+					},
+					Target: averageValue,
+				},
+			},
+		}
+	}
+
+	return spec
+}
+
+// jvbPrometheusQuery sums the metric over the bridges labeled pod
+func jvbPrometheusQuery(namespace string, pod labels.Set, autoscaler v1alpha1.JVBAutoscaler) string {
+	if len(autoscaler.Query) > 0 {
+		return autoscaler.Query
+	}
+
+	l := prometheusLabels(pod)
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	matchers := []string{fmt.Sprintf("namespace=%q", namespace)}
+	for _, key := range keys {
+		matchers = append(matchers, fmt.Sprintf("%s=%q", key, l[key]))
+	}
+
+	return fmt.Sprintf("sum(%s{%s})", prometheusMetricName(autoscaler), strings.Join(matchers, ","))
+}
+
+// jvbScaledObject returns the skeleton of the KEDA ScaledObject scaling the
+// bridges of the Deployment name
+func jvbScaledObject(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(scaledObjectGVK)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+
+	return obj
+}
+
+// setJVBScaledObjectSpec scales the bridges labeled pod of the Deployment
+// named after obj on the sum of their metric in Prometheus
+func setJVBScaledObjectSpec(obj *unstructured.Unstructured, pod labels.Set, strategy v1alpha1.JVBStrategy) error {
+	autoscaler := strategy.AutoscalerSettings()
+
+	spec := map[string]interface{}{
+		"scaleTargetRef": map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"name":       obj.GetName(),
+		},
+		"maxReplicaCount": int64(strategy.MaxReplicas),
+		"triggers": []interface{}{
+			map[string]interface{}{
+				"type":       "prometheus",
+				"metricType": string(autoscalingv2.AverageValueMetricType),
+				"metadata": map[string]interface{}{
+					"serverAddress": autoscaler.PrometheusServer,
+					"query":         jvbPrometheusQuery(obj.GetNamespace(), pod, autoscaler),
+					"threshold":     strconv.FormatFloat(autoscaler.Target.AsApproximateFloat64(), 'f', -1, 64),
+				},
+			},
+		},
+	}
+
+	if strategy.Replicas != nil {
+		spec["minReplicaCount"] = int64(*strategy.Replicas)
+	}
+
+	if autoscaler.Behavior != nil {
+		behavior, err := runtime.DefaultUnstructuredConverter.ToUnstructured(autoscaler.Behavior)
+		if err != nil {
+			return err
+		}
+		spec["advanced"] = map[string]interface{}{
+			"horizontalPodAutoscalerConfig": map[string]interface{}{
+				"behavior": behavior,
+			},
+		}
+	}
+
+	obj.Object["spec"] = spec
+	return nil
+}
+
+// NewJVBScaledObjectSyncer scales the bridges of the shard with KEDA, which
+// manages the HPA on its own
+func NewJVBScaledObjectSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
+	obj := jvbScaledObject(jitsi.Namespace, jitsi.ShardName(shard, "jvb"))
+	strategy := jitsi.JVBStrategy(shard)
+
+	return syncer.NewObjectSyncer("ScaledObject", jitsi, obj, c, func() error {
+		obj.SetLabels(jitsi.ShardLabels(shard, "jvb"))
+
+		return setJVBScaledObjectSpec(obj, jitsi.ShardLabels(shard, "jvb"), strategy)
+	})
+}
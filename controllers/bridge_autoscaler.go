@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+
+	"github.com/tidwall/gjson"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// bridgeAutoscaleInterval is how often the operator polls the bridges it
+// scales
+const bridgeAutoscaleInterval = 30 * time.Second
+
+const (
+	// drainingAnnotation marks a bridge in graceful shutdown, waiting for its
+	// conferences to end before it is removed
+	drainingAnnotation = "apps.jit.si/draining"
+	// podDeletionCostAnnotation makes the ReplicaSet remove the drained
+	// bridge first when the Deployment is scaled down
+	podDeletionCostAnnotation = "controller.kubernetes.io/pod-deletion-cost"
+)
+
+const (
+	reasonBridgesScaledUp   = "BridgesScaledUp"
+	reasonBridgeDraining    = "BridgeDraining"
+	reasonBridgesScaledDown = "BridgesScaledDown"
+	reasonAutoscaleFailed   = "AutoscaleFailed"
+)
+
+var bridgeHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+type bridgeLoad struct {
+	pod          *corev1.Pod
+	value        float64
+	participants int64
+	stress       float64
+}
+
+// getBridgeLoad reads the autoscaled metric and the load of a bridge from
+// its colibri stats
+func getBridgeLoad(pod *corev1.Pod, metric v1alpha1.JVBAutoscalerMetric) (*bridgeLoad, error) {
+	res, err := bridgeHTTPClient.Get(fmt.Sprintf("http://%s:8080/colibri/stats", pod.Status.PodIP))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bridge stats returned %s", res.Status)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := gjson.ParseBytes(body)
+	return &bridgeLoad{
+		pod:          pod,
+		value:        stats.Get(jvbMetrics[metric].statsKey).Float(),
+		participants: stats.Get("participants").Int(),
+		stress:       stats.Get("stress_level").Float(),
+	}, nil
+}
+
+// shutdownBridge starts the graceful shutdown of a bridge through its REST
+// API, it stops accepting conferences and exits once the last one ended
+func shutdownBridge(pod *corev1.Pod) error {
+	res, err := bridgeHTTPClient.Post(fmt.Sprintf("http://%s:8080/colibri/shutdown", pod.Status.PodIP),
+		"application/json", strings.NewReader(`{"graceful-shutdown": "true"}`))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("bridge shutdown returned %s", res.Status)
+	}
+	return nil
+}
+
+// autoscaledReplicas returns the replicas of the Deployment of bridges the
+// operator scales, keeping the ones it set within the bounds of the strategy
+func autoscaledReplicas(current *int32, strategy v1alpha1.JVBStrategy) *int32 {
+	if !strategy.OperatorAutoscaled() || current == nil {
+		return strategy.Replicas
+	}
+
+	replicas := *current
+	if strategy.Replicas != nil && replicas < *strategy.Replicas {
+		replicas = *strategy.Replicas
+	}
+	if replicas > strategy.MaxReplicas {
+		replicas = strategy.MaxReplicas
+	}
+	return &replicas
+}
+
+// autoscaleBridges scales the Deployment of bridges on the sum of their
+// metric. Bridges are added right away but removed one at a time: the least
+// loaded bridge is put in graceful shutdown first, and the Deployment is
+// only scaled down, removing that bridge, once its conferences ended.
+func autoscaleBridges(ctx context.Context, c client.Client, dep *appsv1.Deployment, strategy v1alpha1.JVBStrategy, record func(reason, message string)) error {
+	if err := c.Get(ctx, client.ObjectKeyFromObject(dep), dep); err != nil {
+		return ignoreNotFound(err)
+	}
+	if dep.Spec.Replicas == nil || dep.Spec.Selector == nil {
+		return nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(dep.Namespace), client.MatchingLabels(dep.Spec.Selector.MatchLabels)); err != nil {
+		return err
+	}
+
+	autoscaler := strategy.AutoscalerSettings()
+	var draining *corev1.Pod
+	loads := []*bridgeLoad{}
+	total := 0.0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if _, ok := pod.Annotations[drainingAnnotation]; ok {
+			draining = pod
+			continue
+		}
+
+		// bridges still starting count as idle
+		if len(pod.Status.PodIP) == 0 {
+			continue
+		}
+		load, err := getBridgeLoad(pod, autoscaler.Metric)
+		if err != nil {
+			continue
+		}
+		loads = append(loads, load)
+		total += load.value
+	}
+
+	if len(loads) == 0 {
+		return nil
+	}
+
+	desired := int32(math.Ceil(total / autoscaler.Target.AsApproximateFloat64()))
+	if strategy.Replicas != nil && desired < *strategy.Replicas {
+		desired = *strategy.Replicas
+	}
+	if desired > strategy.MaxReplicas {
+		desired = strategy.MaxReplicas
+	}
+
+	// the drained bridge is still counted by the Deployment until it is
+	// removed
+	active := *dep.Spec.Replicas
+	if draining != nil {
+		active--
+	}
+
+	if desired > active {
+		return scaleBridges(ctx, c, dep, *dep.Spec.Replicas+desired-active, record, reasonBridgesScaledUp,
+			fmt.Sprintf("%s at %.2f over %d bridges", autoscaler.Metric, total, len(loads)))
+	}
+
+	if draining != nil {
+		// a bridge which exited or can no longer be reached is done too. A
+		// bridge restarted after its shutdown rejoins the brewery, so the
+		// shutdown is requested again until it is removed.
+		if load, err := getBridgeLoad(draining, autoscaler.Metric); err == nil && load.participants > 0 {
+			_ = shutdownBridge(draining)
+			return nil
+		}
+		return scaleBridges(ctx, c, dep, *dep.Spec.Replicas-1, record, reasonBridgesScaledDown,
+			fmt.Sprintf("bridge %s drained", draining.Name))
+	}
+
+	if desired < active {
+		// only bridges whose stats are known can be drained
+		sort.Slice(loads, func(i, j int) bool {
+			if loads[i].participants != loads[j].participants {
+				return loads[i].participants < loads[j].participants
+			}
+			return loads[i].stress < loads[j].stress
+		})
+		return drainBridge(ctx, c, loads[0].pod, record)
+	}
+
+	return nil
+}
+
+func scaleBridges(ctx context.Context, c client.Client, dep *appsv1.Deployment, replicas int32, record func(reason, message string), reason, why string) error {
+	current := *dep.Spec.Replicas
+	patch := client.MergeFrom(dep.DeepCopy())
+	dep.Spec.Replicas = &replicas
+	if err := c.Patch(ctx, dep, patch); err != nil {
+		return err
+	}
+
+	record(reason, fmt.Sprintf("scaled %s from %d to %d bridges, %s", dep.Name, current, replicas, why))
+	return nil
+}
+
+func drainBridge(ctx context.Context, c client.Client, pod *corev1.Pod, record func(reason, message string)) error {
+	if err := shutdownBridge(pod); err != nil {
+		return fmt.Errorf("unable to shut bridge %s down: %w", pod.Name, err)
+	}
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[drainingAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	pod.Annotations[podDeletionCostAnnotation] = fmt.Sprint(math.MinInt32)
+	if err := c.Patch(ctx, pod, patch); err != nil {
+		return err
+	}
+
+	record(reasonBridgeDraining, fmt.Sprintf("draining bridge %s before scaling down", pod.Name))
+	return nil
+}
+
+// setEnvVar sets the variable of the container, replacing its value if it
+// is already set
+func setEnvVar(container *corev1.Container, name, value string) {
+	for i := range container.Env {
+		if container.Env[i].Name == name {
+			container.Env[i] = corev1.EnvVar{Name: name, Value: value}
+			return
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+}
@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// bridgesPath is where the bridges find the port and the address allocated
+// to them, read by the cont-init script of the jvb image
+const bridgesPath = "/var/run/jitsi/bridges"
+
+// bridgeAddressInterval is how often the addresses of pending load balancers
+// are checked
+const bridgeAddressInterval = 10 * time.Second
+
+// bridgePortAnnotation records the UDP port allocated to a bridge pod, which
+// keeps it for its whole life
+const bridgePortAnnotation = "apps.jit.si/bridge-port"
+
+// bridgePodsChanged filters the bridges that need a Service or lose theirs
+var bridgePodsChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return false
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+func jvbBridgesConfigMapName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-jvb-bridges", jitsi.Name)
+}
+
+func jvbPoolBridgesConfigMapName(pool *v1alpha1.JVBPool) string {
+	return fmt.Sprintf("%s-bridges", pool.Name)
+}
+
+// bridgeExposure allocates a port of the range of jvb to each bridge pod
+// matching selector and returns the syncers of their Services, controlled by
+// the pods so that they go along with them, and of the ConfigMap telling the
+// bridges their port and address. Bridges keep the port recorded on them, or
+// the one of their Service, new ones get the lowest free port in the order
+// they were created. The pods are read from the API server, a stale cache
+// would hand out ports already taken. pending reports load balancers without
+// an address yet.
+func bridgeExposure(ctx context.Context, c client.Client, reader client.Reader, owner client.Object, jvb *v1alpha1.JVB, selector labels.Set, configMap string) (syncers []syncer.Interface, pending bool, err error) {
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods, client.InNamespace(owner.GetNamespace()), client.MatchingLabels(selector)); err != nil {
+		return nil, false, fmt.Errorf("unable to list the bridges: %w", err)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		a, b := pods.Items[i].CreationTimestamp, pods.Items[j].CreationTimestamp
+		if !a.Equal(&b) {
+			return a.Before(&b)
+		}
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	services := &corev1.ServiceList{}
+	if err := c.List(ctx, services, client.InNamespace(owner.GetNamespace()), client.MatchingLabels(selector), client.HasLabels{v1alpha1.BridgeLabel}); err != nil {
+		return nil, false, fmt.Errorf("unable to list the services of the bridges: %w", err)
+	}
+
+	first, last := jvb.PortRange()
+	ports := map[string]int32{}
+	used := map[int32]bool{}
+	claim := func(pod *corev1.Pod, port int32) {
+		if _, ok := ports[pod.Name]; ok || port < first || port > last || used[port] {
+			return
+		}
+		ports[pod.Name] = port
+		used[port] = true
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if port, err := strconv.ParseInt(pod.Annotations[bridgePortAnnotation], 10, 32); err == nil {
+			claim(pod, int32(port))
+		}
+	}
+	existing := map[string]*corev1.Service{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		for j := range services.Items {
+			svc := &services.Items[j]
+			if svc.Name != bridgeServiceName(pod) || !metav1.IsControlledBy(svc, pod) {
+				continue
+			}
+			existing[pod.Name] = svc
+			// bridges created before their port was recorded on them
+			if len(svc.Spec.Ports) > 0 {
+				claim(pod, svc.Spec.Ports[0].Port)
+			}
+		}
+	}
+
+	data := map[string]string{}
+	next := first
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !pod.DeletionTimestamp.IsZero() && ports[pod.Name] == 0 {
+			continue
+		}
+
+		port, ok := ports[pod.Name]
+		if !ok {
+			for next <= last && used[next] {
+				next++
+			}
+			if next > last {
+				return nil, false, fmt.Errorf("no port left in %d-%d for bridge %s", first, last, pod.Name)
+			}
+			port = next
+			used[port] = true
+		}
+
+		recorded := strconv.FormatInt(int64(port), 10)
+		if pod.Labels[v1alpha1.BridgeLabel] != bridgeServiceName(pod) || pod.Annotations[bridgePortAnnotation] != recorded {
+			// the lock fails the patch of a pod changed since it was read
+			patch := client.MergeFromWithOptions(pod.DeepCopy(), client.MergeFromWithOptimisticLock{})
+			pod.Labels[v1alpha1.BridgeLabel] = bridgeServiceName(pod)
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[bridgePortAnnotation] = recorded
+			if err := c.Patch(ctx, pod, patch); err != nil {
+				return nil, false, fmt.Errorf("unable to record the port of bridge %s: %w", pod.Name, err)
+			}
+		}
+
+		data[pod.Name+".port"] = recorded
+		if jvb.TCP != nil {
+			data[pod.Name+".tcp-port"] = strconv.FormatInt(int64(jvb.TCPPort(port)), 10)
+		}
+		if jvb.Exposure.Type == v1alpha1.JVBExposureLoadBalancer {
+			if address := loadBalancerAddress(existing[pod.Name]); len(address) > 0 {
+				data[pod.Name+".address"] = address
+			} else {
+				pending = true
+			}
+		}
+
+		syncers = append(syncers, NewBridgeServiceSyncer(pod, jvb, selector, port, c))
+	}
+
+	syncers = append(syncers, newBridgesConfigMapSyncer(owner, configMap, selector, data, c))
+
+	return syncers, pending, nil
+}
+
+// bridgeServiceName names the Service of the bridge pod, and labels the pod
+// for its selector. Pod names that are not valid Service names, too long or
+// with dots, are truncated and end with a hash of the pod name to stay unique.
+func bridgeServiceName(pod *corev1.Pod) string {
+	if len(validation.IsDNS1035Label(pod.Name)) == 0 {
+		return pod.Name
+	}
+	suffix := fmt.Sprintf("-%x", sha256.Sum256([]byte(pod.Name)))[:9]
+	name := strings.ReplaceAll(pod.Name, ".", "-")
+	if len(name) > validation.DNS1035LabelMaxLength-len(suffix) {
+		name = name[:validation.DNS1035LabelMaxLength-len(suffix)]
+	}
+	return strings.TrimRight(name, "-") + suffix
+}
+
+func loadBalancerAddress(svc *corev1.Service) string {
+	if svc == nil {
+		return ""
+	}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if len(ingress.IP) > 0 {
+			return ingress.IP
+		}
+	}
+	return ""
+}
+
+// NewBridgeServiceSyncer exposes the UDP port allocated to the bridge pod on
+// the same port of its node or load balancer, where the bridge advertises it
+func NewBridgeServiceSyncer(pod *corev1.Pod, jvb *v1alpha1.JVB, l labels.Set, port int32, c client.Client) syncer.Interface {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bridgeServiceName(pod),
+			Namespace: pod.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("Service", pod, svc, c, func() error {
+		svc.Labels = labels.Merge(l, labels.Set{v1alpha1.BridgeLabel: bridgeServiceName(pod)})
+		svc.Annotations = jvb.Exposure.Annotations
+		svc.Spec.Type = corev1.ServiceType(jvb.Exposure.Type)
+		svc.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+		svc.Spec.Selector = map[string]string{
+			v1alpha1.BridgeLabel: bridgeServiceName(pod),
+		}
+
+		servicePort := corev1.ServicePort{
+			Name:       "rtp-udp",
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+			Protocol:   corev1.ProtocolUDP,
+		}
+		// keep the node ports allocated to the load balancer
+		previous := svc.Spec.Ports
+		if jvb.Exposure.Type == v1alpha1.JVBExposureNodePort {
+			servicePort.NodePort = port
+		} else if len(previous) > 0 {
+			servicePort.NodePort = previous[0].NodePort
+		}
+		svc.Spec.Ports = []corev1.ServicePort{servicePort}
+
+		if jvb.TCP != nil {
+			tcpPort := corev1.ServicePort{
+				Name:       "rtp-tcp",
+				Port:       jvb.TCPPort(port),
+				TargetPort: intstr.FromInt(int(jvb.TCPPort(port))),
+				Protocol:   corev1.ProtocolTCP,
+			}
+			if jvb.Exposure.Type == v1alpha1.JVBExposureNodePort {
+				tcpPort.NodePort = tcpPort.Port
+			} else if advertised := jvb.TCP.AdvertisedPort; advertised != nil {
+				// the load balancer of the bridge listens where the bridge
+				// advertises
+				tcpPort.Port = *advertised
+			}
+			if len(previous) > 1 && jvb.Exposure.Type == v1alpha1.JVBExposureLoadBalancer {
+				tcpPort.NodePort = previous[1].NodePort
+			}
+			svc.Spec.Ports = append(svc.Spec.Ports, tcpPort)
+		}
+
+		return nil
+	})
+}
+
+func newBridgesConfigMapSyncer(owner client.Object, name string, l labels.Set, data map[string]string, c client.Client) syncer.Interface {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: owner.GetNamespace(),
+		},
+	}
+
+	return syncer.NewObjectSyncer("ConfigMap", owner, cm, c, func() error {
+		cm.Labels = l
+		cm.Data = data
+
+		return nil
+	})
+}
+
+// injectExposure makes the bridge of the pod listen on the port allocated to
+// it, and advertise the address of its load balancer, rather than binding a
+// host port
+func injectExposure(jvb *v1alpha1.JVB, configMap string, template *corev1.PodTemplateSpec) {
+	if !jvb.ServiceExposed() {
+		return
+	}
+
+	container := &template.Spec.Containers[0]
+	ports := []corev1.ContainerPort{}
+	for _, port := range container.Ports {
+		if port.Name != "rtp-udp" && port.Name != "rtp-tcp" {
+			ports = append(ports, port)
+		}
+	}
+	container.Ports = ports
+
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      "bridges",
+		MountPath: bridgesPath,
+		ReadOnly:  true,
+	})
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: "bridges",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+			},
+		},
+	})
+
+	container.Env = append(container.Env,
+		corev1.EnvVar{
+			Name: "JVB_BRIDGE_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "metadata.name",
+				},
+			},
+		},
+		corev1.EnvVar{
+			Name:  "JVB_PORT_FILE",
+			Value: path.Join(bridgesPath, "$(JVB_BRIDGE_NAME).port"),
+		},
+	)
+	if jvb.TCP != nil {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "JVB_TCP_PORT_FILE",
+			Value: path.Join(bridgesPath, "$(JVB_BRIDGE_NAME).tcp-port"),
+		})
+	}
+
+	if jvb.Exposure.Type == v1alpha1.JVBExposureLoadBalancer {
+		setEnvVar(container, "JVB_ADVERTISE_IPS_FILE", path.Join(bridgesPath, "$(JVB_BRIDGE_NAME).address"))
+		setEnvVar(container, "JVB_DISABLE_STUN", "1")
+	}
+}
+
+// jitsiOfBridge enqueues the instance of a bridge pod
+func jitsiOfBridge(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := obj.GetLabels()
+	if l["app.kubernetes.io/component"] != "jvb" || len(l["app.kubernetes.io/instance"]) == 0 {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{
+		Namespace: obj.GetNamespace(),
+		Name:      l["app.kubernetes.io/instance"],
+	}}}
+}
+
+// poolOfBridge enqueues the pool of a bridge pod
+func poolOfBridge(ctx context.Context, obj client.Object) []reconcile.Request {
+	pool := obj.GetLabels()[v1alpha1.PoolLabel]
+	if len(pool) == 0 {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{
+		Namespace: obj.GetNamespace(),
+		Name:      pool,
+	}}}
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	reasonCapacityScheduleStarted = "CapacityScheduleStarted"
+	reasonCapacityScheduleEnded   = "CapacityScheduleEnded"
+)
+
+// capacityScheduleInterval is how often the instances with capacity
+// schedules are reconciled, which is when their schedules open and close
+const capacityScheduleInterval = time.Minute
+
+// applyCapacitySchedule raises the replicas of the defaulted jitsi to the
+// capacity schedule open now and reports it in the status
+func (r *JitsiReconciler) applyCapacitySchedule(ctx context.Context, jitsi *v1alpha1.Jitsi) error {
+	schedule, err := jitsi.ActiveCapacitySchedule(time.Now())
+	if err != nil {
+		return err
+	}
+
+	name := ""
+	if schedule != nil {
+		name = schedule.Name
+	}
+	if name != jitsi.Status.CapacitySchedule {
+		if len(name) > 0 {
+			r.event(jitsi, corev1.EventTypeNormal, reasonCapacityScheduleStarted, fmt.Sprintf("capacity schedule %s applies", name))
+		} else {
+			r.event(jitsi, corev1.EventTypeNormal, reasonCapacityScheduleEnded, fmt.Sprintf("capacity schedule %s ended", jitsi.Status.CapacitySchedule))
+		}
+	}
+	jitsi.Status.CapacitySchedule = name
+
+	jitsi.ApplyCapacitySchedule(schedule)
+	return nil
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// contentVersionAnnotation rolls the pods of a component out when the
+// ConfigMaps and Secrets it references change
+const contentVersionAnnotation = "apps.jit.si/content-version"
+
+func hashData(hash hash.Hash, object string, data map[string][]byte) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(hash, "%s\n", object)
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s=%x\n", key, sha256.Sum256(data[key]))
+	}
+}
+
+// syncContentVersions hashes the contents of the ConfigMaps and Secrets
+// referenced by each component into the status. A missing object is hashed
+// as such, its pods fail to start until it is created.
+func (r *JitsiReconciler) syncContentVersions(ctx context.Context, jitsi *v1alpha1.Jitsi) error {
+	versions := map[string]string{}
+	for component, refs := range jitsi.References() {
+		if len(refs.ConfigMaps) == 0 && len(refs.Secrets) == 0 {
+			continue
+		}
+
+		hash := sha256.New()
+		for _, name := range refs.ConfigMaps {
+			cm := &corev1.ConfigMap{}
+			err := r.Client.Get(ctx, client.ObjectKey{Namespace: jitsi.Namespace, Name: name}, cm)
+			if apierrs.IsNotFound(err) {
+				fmt.Fprintf(hash, "configmap/%s missing\n", name)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("unable to get configmap %s: %w", name, err)
+			}
+			data := map[string][]byte{}
+			for key, value := range cm.Data {
+				data[key] = []byte(value)
+			}
+			for key, value := range cm.BinaryData {
+				data[key] = value
+			}
+			hashData(hash, "configmap/"+name, data)
+		}
+		for _, name := range refs.Secrets {
+			secret := &corev1.Secret{}
+			err := r.Client.Get(ctx, client.ObjectKey{Namespace: jitsi.Namespace, Name: name}, secret)
+			if apierrs.IsNotFound(err) {
+				fmt.Fprintf(hash, "secret/%s missing\n", name)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("unable to get secret %s: %w", name, err)
+			}
+			hashData(hash, "secret/"+name, secret.Data)
+		}
+		versions[component] = fmt.Sprintf("%x", hash.Sum(nil))[:16]
+	}
+
+	jitsi.Status.ContentVersions = nil
+	if len(versions) > 0 {
+		jitsi.Status.ContentVersions = versions
+	}
+
+	return nil
+}
+
+// recordingsRunning tells whether Jicofo last reported busy Jibri instances.
+// Missing or stale stats are taken as running recordings.
+func recordingsRunning(jitsi *v1alpha1.Jitsi) bool {
+	stats := jitsi.Status.Stats
+	return stats == nil || stats.Stale || stats.JibriInstances > stats.JibriAvailable
+}
+
+// conferencesRunning tells whether Jicofo last reported running conferences.
+// Missing or stale stats are taken as running conferences.
+func conferencesRunning(jitsi *v1alpha1.Jitsi) bool {
+	stats := jitsi.Status.Stats
+	return stats == nil || stats.Stale || stats.Conferences > 0
+}
+
+// holdBridgesContent tells whether the bridges keep the content they run
+// with: restarting them would end their conferences, unless their graceful
+// shutdown drains them first
+func holdBridgesContent(jitsi *v1alpha1.Jitsi) bool {
+	return !jitsi.Spec.JVB.GracefulShutdown && conferencesRunning(jitsi)
+}
+
+// injectContentVersion rolls the pods of the template out when the content
+// referenced by component changes. With hold the version already rolled out
+// is kept, the change waits for the pods to be idle.
+func injectContentVersion(jitsi *v1alpha1.Jitsi, component string, hold bool, template *corev1.PodTemplateSpec) {
+	if _, rolledOut := template.Annotations[contentVersionAnnotation]; hold && rolledOut {
+		return
+	}
+
+	delete(template.Annotations, contentVersionAnnotation)
+	version, ok := jitsi.Status.ContentVersions[component]
+	if !ok {
+		return
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[contentVersionAnnotation] = version
+}
+
+// contentChanged filters the updates of the ConfigMaps and Secrets that
+// leave their content as it is, e.g. those of their metadata
+var contentChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		switch oldObj := e.ObjectOld.(type) {
+		case *corev1.ConfigMap:
+			newObj, ok := e.ObjectNew.(*corev1.ConfigMap)
+			return !ok || !equality.Semantic.DeepEqual(oldObj.Data, newObj.Data) ||
+				!equality.Semantic.DeepEqual(oldObj.BinaryData, newObj.BinaryData)
+		case *corev1.Secret:
+			newObj, ok := e.ObjectNew.(*corev1.Secret)
+			return !ok || !equality.Semantic.DeepEqual(oldObj.Data, newObj.Data)
+		}
+		return true
+	},
+}
+
+// jitsisReferencing maps a ConfigMap or Secret to the instances of its
+// namespace referencing it
+func (r *JitsiReconciler) jitsisReferencing(kind string) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		jitsis := &v1alpha1.JitsiList{}
+		if err := r.Client.List(ctx, jitsis, client.InNamespace(obj.GetNamespace())); err != nil {
+			r.Log.Error(err, "unable to list the instances referencing "+kind, "name", obj.GetName())
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for i := range jitsis.Items {
+			jitsi := &jitsis.Items[i]
+			jitsi.SetDefaults()
+			if jitsi.Referenced(kind, obj.GetName()) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(jitsi)})
+			}
+		}
+		return requests
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// upstreamEnvVars returns the variables making the bridges join the
+// breweries of the upstream instance on top of the local ones
+func upstreamEnvVars(jitsi *v1alpha1.Jitsi) []corev1.EnvVar {
+	upstream := jitsi.Upstream()
+	if upstream == nil {
+		return nil
+	}
+
+	servers, secret := upstream.Servers, upstream.Secret
+	if upstream.JitsiRef != nil {
+		servers, secret = nil, upstreamSecretKey(jitsi)
+		if jitsi.Status.Upstream != nil {
+			servers = jitsi.Status.Upstream.Servers
+		}
+	}
+	if len(servers) == 0 || secret == nil {
+		return nil
+	}
+
+	envVars := []corev1.EnvVar{
+		{Name: "JVB_UPSTREAM_XMPP_SERVER", Value: strings.Join(servers, ",")},
+		{
+			Name: "JVB_UPSTREAM_XMPP_AUTH_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: secret,
+			},
+		},
+	}
+	if len(upstream.AuthDomain) > 0 {
+		envVars = append(envVars, corev1.EnvVar{Name: "JVB_UPSTREAM_XMPP_AUTH_DOMAIN", Value: upstream.AuthDomain})
+	}
+	if len(upstream.InternalMUCDomain) > 0 {
+		envVars = append(envVars, corev1.EnvVar{Name: "JVB_UPSTREAM_XMPP_INTERNAL_MUC_DOMAIN", Value: upstream.InternalMUCDomain})
+	}
+	return envVars
+}
+
+func upstreamSecretName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-upstream", jitsi.Name)
+}
+
+// upstreamSecretKey is where the bridges read the password of an upstream
+// referenced by jitsiRef
+func upstreamSecretKey(jitsi *v1alpha1.Jitsi) *corev1.SecretKeySelector {
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{
+			Name: upstreamSecretName(jitsi),
+		},
+		Key: "JVB_AUTH_PASSWORD",
+	}
+}
+
+// resolveUpstream resolves the servers of an upstream referenced by
+// spec.federation.upstream.jitsiRef into the status. The bridge password of
+// the upstream is copied into a local secret, as pods cannot reference
+// secrets of other namespaces, and the syncer of that secret is returned.
+// An upstream of another namespace must allow the namespace of jitsi.
+func (r *JitsiReconciler) resolveUpstream(ctx context.Context, jitsi *v1alpha1.Jitsi) (syncer.Interface, error) {
+	upstream := jitsi.Upstream()
+	if upstream == nil || upstream.JitsiRef == nil {
+		jitsi.Status.Upstream = nil
+		return nil, nil
+	}
+
+	key := types.NamespacedName{
+		Name:      upstream.JitsiRef.Name,
+		Namespace: upstream.JitsiRef.Namespace,
+	}
+	if len(key.Namespace) == 0 {
+		key.Namespace = jitsi.Namespace
+	}
+
+	remote := &v1alpha1.Jitsi{}
+	if err := r.Client.Get(ctx, key, remote); err != nil {
+		return nil, fmt.Errorf("unable to get upstream %s: %w", key, err)
+	}
+	if !remote.AllowsDownstream(jitsi.Namespace) {
+		// the password copied while it was allowed must not outlive the grant
+		jitsi.Status.Upstream = nil
+		sec := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      upstreamSecretName(jitsi),
+				Namespace: jitsi.Namespace,
+			},
+		}
+		if err := r.Client.Delete(ctx, sec); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("upstream %s does not list namespace %s in spec.federation.allowedNamespaces", key, jitsi.Namespace)
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: remote.SecretName()}, secret); err != nil {
+		return nil, fmt.Errorf("unable to get the secret of upstream %s: %w", key, err)
+	}
+	password := secret.Data["JVB_AUTH_PASSWORD"]
+	if len(password) == 0 {
+		return nil, fmt.Errorf("upstream %s has no JVB_AUTH_PASSWORD yet", key)
+	}
+
+	servers := []string{}
+	shards := remote.Shards()
+	for i := range shards {
+		servers = append(servers,
+			fmt.Sprintf("%s.%s.svc.cluster.local:5222", remote.ShardName(&shards[i], "prosody"), remote.Namespace))
+	}
+	jitsi.Status.Upstream = &v1alpha1.UpstreamStatus{Servers: servers}
+
+	return NewUpstreamSecretSyncer(jitsi, password, r.Client), nil
+}
+
+func NewUpstreamSecretSyncer(jitsi *v1alpha1.Jitsi, password []byte, c client.Client) syncer.Interface {
+	sec := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      upstreamSecretName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("Secret", jitsi, sec, c, func() error {
+		sec.Labels = jitsi.ComponentLabels("jvb")
+		sec.Data = map[string][]byte{
+			"JVB_AUTH_PASSWORD": password,
+		}
+
+		return nil
+	})
+}
+
+// NewProsodyFederationServiceSyncer publishes the XMPP port of the Prosody
+// of the shard for the bridges of other clusters
+func NewProsodyFederationServiceSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jitsi.ShardName(shard, "prosody-federation"),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("Service", jitsi, svc, c, func() error {
+		expose := jitsi.Spec.Federation.Expose
+
+		svc.Labels = jitsi.ShardLabels(shard, "prosody")
+		svc.Annotations = expose.Annotations
+		svc.Spec.Type = expose.Type
+		if len(svc.Spec.Type) == 0 {
+			svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+		}
+		svc.Spec.Selector = jitsi.ShardLabels(shard, "prosody")
+		svc.Spec.Ports = []corev1.ServicePort{
+			{
+				Name:       "xmpp",
+				Port:       5222,
+				TargetPort: intstr.FromInt(5222),
+				Protocol:   corev1.ProtocolTCP,
+			},
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+	"github.com/presslabs/controller-util/pkg/syncer"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	reasonGarbageCollected = "GarbageCollected"
+	reasonDeleteFailed     = "DeleteFailed"
+)
+
+// ownedKinds are the kinds of objects the operator creates for an instance
+func ownedKinds() []client.ObjectList {
+	return []client.ObjectList{
+		&appsv1.DeploymentList{},
+		&appsv1.DaemonSetList{},
+		&appsv1.StatefulSetList{},
+		&autoscalingv2.HorizontalPodAutoscalerList{},
+		&corev1.ServiceList{},
+		&corev1.ConfigMapList{},
+		&corev1.SecretList{},
+		&networkingv1.IngressList{},
+		&monitoringv1.PodMonitorList{},
+		&monitoringv1.ServiceMonitorList{},
+		scaledObjectList(),
+		certificateList(),
+	}
+}
+
+func certificateList() *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(certificateGVK.GroupVersion().WithKind("CertificateList"))
+	return list
+}
+
+func scaledObjectList() *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(scaledObjectGVK.GroupVersion().WithKind("ScaledObjectList"))
+	return list
+}
+
+func (r *JitsiReconciler) objectKey(obj client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.Client.Scheme())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s", gvk.GroupKind(), obj.GetName()), nil
+}
+
+// collectGarbage deletes the objects controlled by jitsi that none of the
+// desired syncers produce anymore, e.g. the JVB Deployment once the strategy
+// switched to daemonset. Every component is labeled with the instance labels,
+// so they select the objects of all components at once.
+func (r *JitsiReconciler) collectGarbage(ctx context.Context, jitsi *v1alpha1.Jitsi, desired []syncer.Interface) error {
+	keep := map[string]bool{}
+	for _, s := range desired {
+		key, err := r.objectKey(s.Object().(client.Object))
+		if err != nil {
+			return err
+		}
+		keep[key] = true
+	}
+
+	errs := []error{}
+	for _, list := range ownedKinds() {
+		if err := r.Client.List(ctx, list, client.InNamespace(jitsi.Namespace), client.MatchingLabels(jitsi.Labels())); err != nil {
+			// monitors, scaled objects and certificates are only known to
+			// clusters running the prometheus operator, KEDA and cert-manager
+			if !meta.IsNoMatchError(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		objects, err := meta.ExtractList(list)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, o := range objects {
+			obj := o.(client.Object)
+			if !metav1.IsControlledBy(obj, jitsi) || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			key, err := r.objectKey(obj)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if keep[key] {
+				continue
+			}
+
+			if err := ignoreNotFound(r.Client.Delete(ctx, obj)); err != nil {
+				r.event(jitsi, corev1.EventTypeWarning, reasonDeleteFailed, fmt.Sprintf("failed to delete %s: %s", key, err))
+				errs = append(errs, err)
+				continue
+			}
+			r.Log.Info(fmt.Sprintf("deleted %s, no longer desired", key))
+			r.event(jitsi, corev1.EventTypeNormal, reasonGarbageCollected, fmt.Sprintf("deleted %s, no longer desired", key))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// updateStatus writes the status of jitsi, retrying on conflicts with the
+// latest version of the object
+func (r *JitsiReconciler) updateStatus(ctx context.Context, jitsi *v1alpha1.Jitsi) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha1.Jitsi{}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(jitsi), latest); err != nil {
+			return err
+		}
+		latest.Status = jitsi.Status
+		if err := r.Client.Status().Update(ctx, latest); err != nil {
+			return err
+		}
+		jitsi.ResourceVersion = latest.ResourceVersion
+		return nil
+	})
+}
+
+func (r *JitsiReconciler) event(jitsi *v1alpha1.Jitsi, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(jitsi, eventType, reason, message)
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// iceServersAnnotation rolls Prosody out when the ICE servers change
+const iceServersAnnotation = "apps.jit.si/ice-servers"
+
+// externalServicesFile is the Prosody configuration offering the ICE servers
+// to the clients, included after the jitsi-meet.cfg.lua of the image
+const externalServicesFile = "external-services.cfg.lua"
+
+// iceService is an entry of the external_services of Prosody. Credentials are
+// read by Prosody from its environment so that they stay out of the
+// ConfigMap.
+type iceService struct {
+	typ       string
+	transport string
+	host      string
+	port      int32
+	secretEnv string
+	username  string
+	passwdEnv string
+	priority  int32
+}
+
+func (service iceService) lua() string {
+	fields := []string{
+		fmt.Sprintf("type = %q", service.typ),
+		fmt.Sprintf("host = %q", service.host),
+		fmt.Sprintf("port = %d", service.port),
+	}
+	if len(service.transport) > 0 {
+		fields = append(fields, fmt.Sprintf("transport = %q", service.transport))
+	}
+	if len(service.secretEnv) > 0 {
+		fields = append(fields,
+			fmt.Sprintf("secret = Lua.os.getenv(%q)", service.secretEnv),
+			"ttl = 86400",
+			`algorithm = "turn"`,
+		)
+	}
+	if len(service.username) > 0 {
+		fields = append(fields,
+			fmt.Sprintf("username = %q", service.username),
+			fmt.Sprintf("password = Lua.os.getenv(%q)", service.passwdEnv),
+		)
+	}
+
+	return "{ " + strings.Join(fields, ", ") + " }"
+}
+
+// iceServices lists the servers of spec.turn and spec.iceServers, highest
+// priority first, along with the variables holding their credentials
+func iceServices(jitsi *v1alpha1.Jitsi) ([]iceService, []corev1.EnvVar) {
+	services := []iceService{}
+	envVars := []corev1.EnvVar{}
+
+	if turn := jitsi.Spec.TURN; turn != nil {
+		secretEnv := ""
+		if turn.Managed != nil || turn.Secret != nil {
+			// set by turnEnvVars or the prosody syncer
+			secretEnv = "TURN_CREDENTIALS"
+		}
+		switch {
+		case turn.Managed != nil:
+			services = append(services,
+				iceService{typ: "turn", transport: "udp", host: turn.Host, port: v1alpha1.TURNPort, secretEnv: secretEnv},
+				iceService{typ: "turn", transport: "tcp", host: turn.Host, port: v1alpha1.TURNPort, secretEnv: secretEnv},
+			)
+			if turn.TLS {
+				services = append(services, iceService{typ: "turns", transport: "tcp", host: turn.Host, port: int32(turn.Port), secretEnv: secretEnv})
+			}
+		case turn.TLS:
+			services = append(services, iceService{typ: "turns", transport: "tcp", host: turn.Host, port: int32(turn.Port), secretEnv: secretEnv})
+		default:
+			transport := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "TURN_TRANSPORT")
+			if len(transport) == 0 {
+				transport = "udp"
+			}
+			services = append(services, iceService{typ: "turn", transport: transport, host: turn.Host, port: int32(turn.Port), secretEnv: secretEnv})
+		}
+	}
+
+	for i := range jitsi.Spec.ICEServers {
+		server := &jitsi.Spec.ICEServers[i]
+		service := iceService{
+			typ:      string(server.Type),
+			host:     server.Host,
+			port:     server.ServerPort(),
+			priority: server.Priority,
+		}
+
+		if server.Type == v1alpha1.ICEServerTURN {
+			switch server.Protocol {
+			case v1alpha1.ICEProtocolTLS:
+				service.typ = "turns"
+				service.transport = "tcp"
+			case v1alpha1.ICEProtocolTCP:
+				service.transport = "tcp"
+			default:
+				service.transport = "udp"
+			}
+		}
+
+		if server.Secret != nil {
+			service.secretEnv = fmt.Sprintf("ICE_SERVER_%d_SECRET", i)
+			envVars = append(envVars, corev1.EnvVar{
+				Name:      service.secretEnv,
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: server.Secret},
+			})
+		}
+		if server.Password != nil {
+			service.username = server.Username
+			service.passwdEnv = fmt.Sprintf("ICE_SERVER_%d_PASSWORD", i)
+			envVars = append(envVars, corev1.EnvVar{
+				Name:      service.passwdEnv,
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: server.Password},
+			})
+		}
+
+		services = append(services, service)
+	}
+
+	sort.SliceStable(services, func(i, j int) bool {
+		return services[i].priority > services[j].priority
+	})
+
+	return services, envVars
+}
+
+// externalServicesConfig renders the external_services of the virtual hosts
+// the clients log in, overriding the single server the image configures from
+// the TURN variables
+func externalServicesConfig(jitsi *v1alpha1.Jitsi) string {
+	services, _ := iceServices(jitsi)
+
+	domains := []string{jitsi.XMPPDomain()}
+	if guests := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "ENABLE_GUESTS"); guests == "1" || guests == "true" {
+		guest := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "XMPP_GUEST_DOMAIN")
+		if len(guest) == 0 {
+			guest = "guest.meet.jitsi"
+		}
+		domains = append(domains, guest)
+	}
+
+	var cfg strings.Builder
+	cfg.WriteString("-- generated by the jitsi operator from spec.turn and spec.iceServers\n")
+	for _, domain := range domains {
+		fmt.Fprintf(&cfg, "\nVirtualHost %q\n    external_services = {\n", domain)
+		for _, service := range services {
+			fmt.Fprintf(&cfg, "        %s;\n", service.lua())
+		}
+		cfg.WriteString("    }\n")
+	}
+
+	return cfg.String()
+}
+
+func prosodyICEConfigMapName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-prosody-ice", jitsi.Name)
+}
+
+func NewProsodyICEConfigMapSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prosodyICEConfigMapName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("ConfigMap", jitsi, cm, c, func() error {
+		cm.Labels = jitsi.ComponentLabels("prosody")
+		cm.Data = map[string]string{
+			externalServicesFile: externalServicesConfig(jitsi),
+		}
+
+		return nil
+	})
+}
+
+// injectICEServers mounts the external_services of spec.iceServers into
+// Prosody. The image only loads the module serving them when a TURN host is
+// set, the first server stands in when spec.turn is not.
+func injectICEServers(jitsi *v1alpha1.Jitsi, template *corev1.PodTemplateSpec, container *corev1.Container) {
+	delete(template.Annotations, iceServersAnnotation)
+	if len(jitsi.Spec.ICEServers) == 0 {
+		return
+	}
+
+	services, envVars := iceServices(jitsi)
+	container.Env = append(container.Env, envVars...)
+	if jitsi.Spec.TURN == nil {
+		setEnvVar(container, "TURN_HOST", services[0].host)
+		setEnvVar(container, "TURN_PORT", fmt.Sprint(services[0].port))
+	}
+
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: "ice",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: prosodyICEConfigMapName(jitsi),
+				},
+			},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      "ice",
+		MountPath: path.Join(prosodyIncludesDir, externalServicesFile),
+		SubPath:   externalServicesFile,
+	})
+
+	// Prosody does not reload its configuration by itself
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[iceServersAnnotation] = fmt.Sprintf("%x", sha256.Sum256([]byte(externalServicesConfig(jitsi))))
+}
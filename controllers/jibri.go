@@ -34,11 +34,11 @@ func injectJibriAffinity(jitsi *v1alpha1.Jitsi, pod *corev1.PodSpec) {
 
 }
 
-func NewJibriDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
-	dep := jitsi.JibriDeployment()
+func NewJibriDeploymentSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
+	dep := jitsi.JibriDeployment(shard)
 
 	return syncer.NewObjectSyncer("Deployment", jitsi, &dep, c, func() error {
-		dep.Labels = jitsi.ComponentLabels("jibri")
+		dep.Labels = jitsi.ShardLabels(shard, "jibri")
 		dep.Spec.Template.Labels = dep.Labels
 		dep.Spec.Selector = &metav1.LabelSelector{
 			MatchLabels: dep.Labels,
@@ -64,7 +64,7 @@ func NewJibriDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Int
 			},
 		}
 
-		envVars := append(jitsi.EnvVars(JibriVariables),
+		envVars := append(jitsi.ShardEnvVars(shard, &jitsi.Spec.Jibri.ComponentEnv, v1alpha1.JibriVariables),
 			corev1.EnvVar{
 				Name: "LOCAL_ADDRESS",
 				ValueFrom: &corev1.EnvVarSource{
@@ -86,7 +86,7 @@ func NewJibriDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Int
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JIBRI_XMPP_PASSWORD",
 					},
@@ -97,7 +97,7 @@ func NewJibriDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Int
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JIBRI_RECORDER_PASSWORD",
 					},
@@ -149,7 +149,7 @@ func NewJibriDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Int
 			VolumeMounts: []corev1.VolumeMount{
 				{
 					Name:      "recordings",
-					MountPath: jitsi.EnvVarValue("JIBRI_RECORDING_DIR"),
+					MountPath: jitsi.ComponentEnvVarValue(&jitsi.Spec.Jibri.ComponentEnv, "JIBRI_RECORDING_DIR"),
 				},
 				{
 					Name:      "dev-shm",
@@ -173,10 +173,14 @@ func NewJibriDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Int
 		}
 
 		dep.Spec.Template.Spec.Containers = []corev1.Container{jibriContainer}
+		injectSecretsVersion(jitsi, "jibri", &dep.Spec.Template)
+		// restarting would end the running recordings
+		injectContentVersion(jitsi, "jibri", recordingsRunning(jitsi), &dep.Spec.Template)
 
 		injectJibriAffinity(jitsi, &dep.Spec.Template.Spec)
 
-		return nil
+		dep.Spec.Template.Spec.InitContainers = nil
+		return applyPodOverrides(&jitsi.Spec.Jibri.PodOverrides, jitsi.Spec.Jibri.Env, &dep.Spec.Template)
 	})
 
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
 
@@ -16,16 +17,16 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func NewJicofoDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+func NewJicofoDeploymentSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-jicofo", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "jicofo"),
 			Namespace: jitsi.Namespace,
 		},
 	}
 
 	return syncer.NewObjectSyncer("Deployment", jitsi, dep, c, func() error {
-		dep.Labels = jitsi.ComponentLabels("jicofo")
+		dep.Labels = jitsi.ShardLabels(shard, "jicofo")
 		dep.Spec.Template.Labels = dep.Labels
 		dep.Spec.Selector = &metav1.LabelSelector{
 			MatchLabels: dep.Labels,
@@ -34,13 +35,13 @@ func NewJicofoDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.In
 		dep.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
 		dep.Spec.Template.Spec.Affinity = &jitsi.Spec.Jicofo.Affinity
 
-		envVars := append(jitsi.EnvVars(JicofoVariables),
+		envVars := append(jitsi.ShardEnvVars(shard, &jitsi.Spec.Jicofo.ComponentEnv, v1alpha1.JicofoVariables),
 			corev1.EnvVar{
 				Name: "JICOFO_COMPONENT_SECRET",
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JICOFO_COMPONENT_SECRET",
 					},
@@ -51,7 +52,7 @@ func NewJicofoDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.In
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JICOFO_AUTH_PASSWORD",
 					},
@@ -77,15 +78,19 @@ func NewJicofoDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.In
 		}
 
 		dep.Spec.Template.Spec.Containers = []corev1.Container{container}
+		dep.Spec.Template.Spec.InitContainers = nil
+		dep.Spec.Template.Spec.Volumes = nil
+		injectSecretsVersion(jitsi, "jicofo", &dep.Spec.Template)
+		injectContentVersion(jitsi, "jicofo", false, &dep.Spec.Template)
 
-		return nil
+		return applyPodOverrides(&jitsi.Spec.Jicofo.PodOverrides, jitsi.Spec.Jicofo.Env, &dep.Spec.Template)
 	})
 
 }
 
-func (r *JitsiReconciler) findJicofoPod(ctx context.Context, jitsi *v1alpha1.Jitsi) (*corev1.Pod, error) {
+func (r *JitsiReconciler) findJicofoPod(ctx context.Context, jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard) (*corev1.Pod, error) {
 	pods := corev1.PodList{}
-	if err := r.Client.List(ctx, &pods, client.InNamespace(jitsi.Namespace), client.MatchingLabels(jitsi.ComponentLabels("jicofo"))); err != nil {
+	if err := r.Client.List(ctx, &pods, client.InNamespace(jitsi.Namespace), client.MatchingLabels(jitsi.ShardLabels(shard, "jicofo"))); err != nil {
 		return nil, err
 	}
 	if len(pods.Items) > 0 {
@@ -94,18 +99,80 @@ func (r *JitsiReconciler) findJicofoPod(ctx context.Context, jitsi *v1alpha1.Jit
 	return nil, nil
 }
 
-func (r *JitsiReconciler) getConferences(jicofo *corev1.Pod) int64 {
-	if jicofo != nil && jicofo.Status.PodIP != "" {
-		r.Log.Info(jicofo.Status.PodIP)
-		url := fmt.Sprintf("http://%s:8888/stats", jicofo.Status.PodIP)
-		res, _ := http.Get(url)
-		if res != nil {
-			body, err := io.ReadAll(res.Body)
-			if err == nil {
-				r.Log.Info(string(body))
-				return gjson.Get(string(body), "conferences").Int()
+var jicofoHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+// getJicofoStats scrapes the /stats endpoint of the given Jicofo pod
+func (r *JitsiReconciler) getJicofoStats(jicofo *corev1.Pod) (*v1alpha1.ConferenceStats, error) {
+	if jicofo == nil || jicofo.Status.PodIP == "" {
+		return nil, fmt.Errorf("no running jicofo pod")
+	}
+
+	url := fmt.Sprintf("http://%s:8888/stats", jicofo.Status.PodIP)
+	res, err := jicofoHTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jicofo stats returned %s", res.Status)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := gjson.ParseBytes(body)
+	now := metav1.Now()
+	return &v1alpha1.ConferenceStats{
+		Conferences:        stats.Get("conferences").Int(),
+		Participants:       stats.Get("participants").Int(),
+		LargestConference:  stats.Get("largest_conference").Int(),
+		Bridges:            stats.Get("bridge_selector.bridge_count").Int(),
+		OperationalBridges: stats.Get("bridge_selector.operational_bridge_count").Int(),
+		JibriInstances:     stats.Get("jibri_detector.count").Int(),
+		JibriAvailable:     stats.Get("jibri_detector.available").Int(),
+		LastUpdateTime:     &now,
+	}, nil
+}
+
+// refreshStats records the current Jicofo load of all the shards into the
+// status. When a Jicofo cannot be reached the previous values are kept and
+// flagged as stale.
+func (r *JitsiReconciler) refreshStats(ctx context.Context, jitsi *v1alpha1.Jitsi) {
+	total := &v1alpha1.ConferenceStats{}
+	shards := jitsi.Shards()
+	for i := range shards {
+		jicofo, err := r.findJicofoPod(ctx, jitsi, &shards[i])
+		var stats *v1alpha1.ConferenceStats
+		if err == nil {
+			stats, err = r.getJicofoStats(jicofo)
+		}
+
+		if err != nil {
+			if jitsi.Status.Stats == nil {
+				jitsi.Status.Stats = &v1alpha1.ConferenceStats{}
 			}
+			jitsi.Status.Stats.Stale = true
+			jitsi.Status.Stats.Message = err.Error()
+			if len(shards[i].Name) > 0 {
+				jitsi.Status.Stats.Message = fmt.Sprintf("shard %s: %s", shards[i].Name, err)
+			}
+			return
+		}
+
+		total.Conferences += stats.Conferences
+		total.Participants += stats.Participants
+		if stats.LargestConference > total.LargestConference {
+			total.LargestConference = stats.LargestConference
 		}
+		total.Bridges += stats.Bridges
+		total.OperationalBridges += stats.OperationalBridges
+		total.JibriInstances += stats.JibriInstances
+		total.JibriAvailable += stats.JibriAvailable
+		total.LastUpdateTime = stats.LastUpdateTime
 	}
-	return 0
+
+	jitsi.Status.Stats = total
 }
@@ -18,27 +18,40 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/presslabs/controller-util/pkg/syncer"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	appsv1alpha1 "github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
 
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 )
 
+// statsRefreshInterval is how often the conference stats of a running
+// instance are scraped from Jicofo
+const statsRefreshInterval = time.Minute
+
 // JitsiReconciler reconciles a Jitsi object
 type JitsiReconciler struct {
 	client.Client
 	Log      logr.Logger
 	Scheme   *runtime.Scheme
-	recorder record.EventRecorder
+	Recorder record.EventRecorder
+	// APIReader reads the bridges uncached, to allocate their ports
+	APIReader client.Reader
 }
 
 //+kubebuilder:rbac:groups=apps.jit.si,resources=jitsis,verbs=get;list;watch;create;update;patch;delete
@@ -67,69 +80,162 @@ func (r *JitsiReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
 
+	if !jitsi.DeletionTimestamp.IsZero() {
+		return r.teardown(ctx, jitsi)
+	}
+
+	if controllerutil.AddFinalizer(jitsi, teardownFinalizer) {
+		if err := r.Client.Update(ctx, jitsi); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	if jitsi.Spec.Suspend {
-		return ctrl.Result{}, nil
-	}
-
-	if !jitsi.Spec.DisableGracefulUpgrade {
-		if jitsi.Status.LastAttemptedRevision != appsv1alpha1.Version && jitsi.Status.LastAppliedRevision != "" {
-			jicofo, _ := r.findJicofoPod(ctx, jitsi)
-			conferences := r.getConferences(jicofo)
-			if conferences > 0 {
-				r.Log.Info(fmt.Sprintf("%d conferences, requeing reconciliation", conferences))
-				return ctrl.Result{
-					RequeueAfter: 5 * time.Minute,
-				}, nil
+		jitsi.SetCondition(appsv1alpha1.ConditionSuspended, metav1.ConditionTrue, reasonSuspended, "reconciliation is suspended")
+		jitsi.SetCondition(appsv1alpha1.ConditionProgressing, metav1.ConditionFalse, reasonSuspended, "reconciliation is suspended")
+		return ctrl.Result{}, r.updateStatus(ctx, jitsi)
+	}
+	jitsi.SetCondition(appsv1alpha1.ConditionSuspended, metav1.ConditionFalse, reasonReconciling, "reconciliation is active")
+
+	// without the validating webhook an invalid spec can still be stored, it
+	// is not rolled out until it is fixed
+	if err := jitsi.Validate(); err != nil {
+		r.event(jitsi, corev1.EventTypeWarning, reasonInvalidSpec, err.Error())
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonInvalidSpec, err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, jitsi)
+	}
+
+	if upgradePending(jitsi) {
+		requeueAfter, err := r.gateUpgrade(ctx, jitsi)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if requeueAfter > 0 {
+			if err := r.updateStatus(ctx, jitsi); err != nil {
+				return ctrl.Result{}, err
 			}
+			return ctrl.Result{
+				RequeueAfter: requeueAfter,
+			}, nil
 		}
 	}
+	jitsi.SetCondition(appsv1alpha1.ConditionUpgradeBlocked, metav1.ConditionFalse, reasonNoUpgradePending, "no upgrade is pending")
 
 	jitsi.Status.LastAttemptedRevision = appsv1alpha1.Version
-	if err := r.Client.Status().Update(ctx, jitsi); err != nil {
-		return ctrl.Result{}, nil
+	if err := r.updateStatus(ctx, jitsi); err != nil {
+		return ctrl.Result{}, err
 	}
 
 	jitsi.SetDefaults()
 
-	if !jitsi.Spec.Jibri.Enabled {
-		dep := jitsi.JibriDeployment()
-		_ = r.Client.Delete(ctx, &dep)
+	if err := r.applyCapacitySchedule(ctx, jitsi); err != nil {
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+	}
+
+	// synced ahead of the components rolling out with their version
+	secretsWait, err := r.syncSecrets(ctx, jitsi)
+	if err != nil {
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+	}
+	rolloutWait, err := r.rollOutSecrets(ctx, jitsi)
+	if err != nil {
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+	}
+	if secretsWait == 0 || rolloutWait > 0 && rolloutWait < secretsWait {
+		secretsWait = rolloutWait
+	}
+
+	if err := r.syncContentVersions(ctx, jitsi); err != nil {
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+	}
+
+	syncers := []syncer.Interface{}
+	if !jitsi.ExternalSecrets() {
+		// already synced, listed to be kept from garbage collection
+		syncers = append(syncers, NewJitsiSecretSyncer(jitsi, false, r.Client, r.Recorder))
+	}
+
+	syncers = append(syncers, NewProsodyIncludesConfigMapSyncer(jitsi, r.Client))
+	if len(jitsi.Spec.ICEServers) > 0 {
+		syncers = append(syncers, NewProsodyICEConfigMapSyncer(jitsi, r.Client))
+	}
+
+	if len(prosodySnippets(jitsi)) > 0 {
+		syncers = append(syncers, NewProsodySnippetsConfigMapSyncer(jitsi, r.Client))
+	}
+
+	upstreamSecret, err := r.resolveUpstream(ctx, jitsi)
+	if err != nil {
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+	}
+	if upstreamSecret != nil {
+		syncers = append(syncers, upstreamSecret)
 	}
 
-	if jitsi.Spec.JVB.Strategy.Type != appsv1alpha1.JVBStrategyAutoScaled {
-		hpa := jitsi.JVBHPA()
-		_ = r.Client.Delete(ctx, &hpa)
+	shards := jitsi.Shards()
+	for i := range shards {
+		shard := &shards[i]
+		syncers = append(syncers,
+			NewProsodyServiceSyncer(jitsi, shard, r.Client),
+			NewProsodyDeploymentSyncer(jitsi, shard, r.Client),
+			NewJicofoDeploymentSyncer(jitsi, shard, r.Client),
+			NewWebDeploymentSyncer(jitsi, shard, r.Client),
+			NewWebServiceSyncer(jitsi, shard, r.Client),
+		)
+
+		if jitsi.Spec.Jibri.Enabled {
+			syncers = append(syncers, NewJibriDeploymentSyncer(jitsi, shard, r.Client))
+		}
+
+		if jitsi.Spec.Federation != nil && jitsi.Spec.Federation.Expose != nil {
+			syncers = append(syncers, NewProsodyFederationServiceSyncer(jitsi, shard, r.Client))
+		}
 	}
 
-	if jitsi.Spec.JVB.Strategy.Type == appsv1alpha1.JVBStrategyDaemon {
-		dep := jitsi.JVBDeployment()
-		_ = r.Client.Delete(ctx, &dep)
-	} else {
-		dep := jitsi.JVBDaemonSet()
-		_ = r.Client.Delete(ctx, &dep)
+	if jitsi.Sharded() {
+		syncers = append(syncers,
+			NewRouterConfigMapSyncer(jitsi, r.Client),
+			NewRouterPeersServiceSyncer(jitsi, r.Client),
+			NewRouterStatefulSetSyncer(jitsi, r.Client),
+			NewRouterServiceSyncer(jitsi, r.Client),
+		)
 	}
 
-	syncers := []syncer.Interface{
-		NewJitsiSecretSyncer(jitsi, r.Client),
-		NewProsodyServiceSyncer(jitsi, r.Client),
-		NewProsodyDeploymentSyncer(jitsi, r.Client),
-		NewJicofoDeploymentSyncer(jitsi, r.Client),
-		NewWebDeploymentSyncer(jitsi, r.Client),
-		NewWebServiceSyncer(jitsi, r.Client),
+	nodes, err := listNodes(ctx, r.Client, jitsi.Spec.JVB.PublicIP)
+	if err != nil {
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
 	}
+	if nodes != nil {
+		syncers = append(syncers, NewJVBAddressesConfigMapSyncer(jitsi, nodes, r.Client))
+	}
+
+	syncers = append(syncers, jvbSyncers(jitsi, r.Client)...)
 
-	switch jitsi.Spec.JVB.Strategy.Type {
-	case appsv1alpha1.JVBStrategyAutoScaled:
-		syncers = append(syncers, NewJVBDeploymentSyncer(jitsi, r.Client))
-		syncers = append(syncers, NewJVBHPASyncer(jitsi, r.Client))
-	case appsv1alpha1.JVBStrategyDaemon:
-		syncers = append(syncers, NewJVBDaemonSetSyncer(jitsi, r.Client))
-	case appsv1alpha1.JVBStrategyStatic:
-		syncers = append(syncers, NewJVBDeploymentSyncer(jitsi, r.Client))
+	addressesPending := false
+	if jitsi.Spec.JVB.ServiceExposed() {
+		exposure, pending, err := bridgeExposure(ctx, r.Client, r.APIReader, jitsi, &jitsi.Spec.JVB, jitsi.ComponentLabels("jvb"), jvbBridgesConfigMapName(jitsi))
+		if err != nil {
+			jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+		}
+		syncers = append(syncers, exposure...)
+		addressesPending = pending
 	}
 
-	if jitsi.Spec.Jibri.Enabled {
-		syncers = append(syncers, NewJibriDeploymentSyncer(jitsi, r.Client))
+	if jitsi.ManagedTURN() != nil {
+		turn, pending, err := turnSyncers(ctx, r.Client, jitsi)
+		if err != nil {
+			jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+		}
+		syncers = append(syncers, turn...)
+		addressesPending = addressesPending || pending
 	}
 
 	if jitsi.Spec.Ingress.Enabled {
@@ -141,21 +247,91 @@ func (r *JitsiReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		syncers = append(syncers, NewJicofoServiceMonitorSyncer(jitsi, r.Client))
 	}
 
+	if err := r.collectGarbage(ctx, jitsi, syncers); err != nil {
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+	}
+
 	if err := r.sync(ctx, syncers); err != nil {
+		jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, jitsi)})
+	}
+	jitsi.SetCondition(appsv1alpha1.ConditionDegraded, metav1.ConditionFalse, reasonSyncSucceeded, "all components are in sync")
+
+	if err := r.updateComponentsStatus(ctx, jitsi); err != nil {
 		return ctrl.Result{}, err
 	}
 
+	r.refreshStats(ctx, jitsi)
+
+	requeueAfter := statsRefreshInterval
+	if addressesPending {
+		requeueAfter = bridgeAddressInterval
+	}
+	if secretsWait > 0 && secretsWait < requeueAfter {
+		requeueAfter = secretsWait
+	}
+	if len(jitsi.Spec.CapacitySchedules) > 0 && requeueAfter > capacityScheduleInterval {
+		requeueAfter = capacityScheduleInterval
+	}
+	shards = jitsi.Shards()
+	for i := range shards {
+		strategy := jitsi.JVBStrategy(&shards[i])
+		if !strategy.OperatorAutoscaled() {
+			continue
+		}
+		if requeueAfter > bridgeAutoscaleInterval {
+			requeueAfter = bridgeAutoscaleInterval
+		}
+		dep := jitsi.JVBDeployment(&shards[i])
+		if err := autoscaleBridges(ctx, r.Client, &dep, strategy, func(reason, message string) {
+			r.event(jitsi, corev1.EventTypeNormal, reason, message)
+		}); err != nil {
+			r.event(jitsi, corev1.EventTypeWarning, reasonAutoscaleFailed, err.Error())
+			r.Log.Error(err, "unable to autoscale the bridges", "deployment", dep.Name)
+		}
+	}
+
+	jitsi.Status.ObservedGeneration = jitsi.Generation
 	jitsi.Status.LastAppliedRevision = appsv1alpha1.Version
-	if err := r.Client.Status().Update(ctx, jitsi); err != nil {
-		return ctrl.Result{}, nil
+	jitsi.Status.Upgrade = nil
+	if err := r.updateStatus(ctx, jitsi); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{
+		RequeueAfter: requeueAfter,
+	}, nil
+}
+
+func jvbSyncers(jitsi *appsv1alpha1.Jitsi, c client.Client) []syncer.Interface {
+	syncers := []syncer.Interface{}
+	shards := jitsi.Shards()
+	for i := range shards {
+		shard := &shards[i]
+		switch jitsi.JVBStrategy(shard).Type {
+		case appsv1alpha1.JVBStrategyAutoScaled:
+			syncers = append(syncers, NewJVBDeploymentSyncer(jitsi, shard, c))
+			switch jitsi.JVBStrategy(shard).AutoscalerSettings().Backend {
+			case appsv1alpha1.JVBAutoscalerKEDA:
+				syncers = append(syncers, NewJVBScaledObjectSyncer(jitsi, shard, c))
+			case appsv1alpha1.JVBAutoscalerOperator:
+				// scaled by autoscaleBridges
+			default:
+				syncers = append(syncers, NewJVBHPASyncer(jitsi, shard, c))
+			}
+		case appsv1alpha1.JVBStrategyDaemon:
+			syncers = append(syncers, NewJVBDaemonSetSyncer(jitsi, shard, c))
+		case appsv1alpha1.JVBStrategyStatic:
+			syncers = append(syncers, NewJVBDeploymentSyncer(jitsi, shard, c))
+		}
+	}
+	return syncers
 }
 
 func (r *JitsiReconciler) sync(ctx context.Context, syncers []syncer.Interface) error {
 	for _, s := range syncers {
-		if err := syncer.Sync(ctx, s, r.recorder); err != nil {
+		if err := syncer.Sync(ctx, s, r.Recorder); err != nil {
 			return err
 		}
 	}
@@ -173,6 +349,16 @@ func ignoreNotFound(err error) error {
 // SetupWithManager sets up the controller with the Manager.
 func (r *JitsiReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&appsv1alpha1.Jitsi{}).
+		// status writes, like the stats refreshed by every reconciliation,
+		// must not trigger another one, the annotations drive the rotation
+		// of the secrets
+		For(&appsv1alpha1.Jitsi{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.DaemonSet{}).
+		Owns(&appsv1.StatefulSet{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(jitsiOfBridge), builder.WithPredicates(bridgePodsChanged)).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.jitsisOfNode), builder.WithPredicates(nodeAddressesChanged)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.jitsisReferencing("ConfigMap")), builder.WithPredicates(contentChanged)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.jitsisReferencing("Secret")), builder.WithPredicates(contentChanged)).
 		Complete(r)
 }
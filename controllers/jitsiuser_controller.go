@@ -0,0 +1,355 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// accountFinalizer holds a deleted JitsiUser until its account is removed
+// from Prosody
+const accountFinalizer = "apps.jit.si/prosody-account"
+
+const (
+	reasonAuthNotInternal   = "AuthNotInternal"
+	reasonPasswordNotFound  = "PasswordNotFound"
+	reasonProsodyNotReady   = "ProsodyNotReady"
+	reasonAccountRegistered = "AccountRegistered"
+	reasonAccountRemoved    = "AccountRemoved"
+)
+
+// JitsiUserReconciler reconciles a JitsiUser object
+type JitsiUserReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Config   *rest.Config
+
+	prosody *prosodyExecutor
+}
+
+//+kubebuilder:rbac:groups=apps.jit.si,resources=jitsiusers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps.jit.si,resources=jitsiusers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=apps.jit.si,resources=jitsiusers/finalizers,verbs=update
+
+// Reconcile registers the user on every Prosody pod of its instance. Prosody
+// keeps its accounts in the pod, they are registered again on new pods.
+func (r *JitsiUserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("jitsiuser", req.NamespacedName)
+
+	user := &v1alpha1.JitsiUser{}
+	if err := r.Client.Get(ctx, req.NamespacedName, user); err != nil {
+		return ctrl.Result{}, ignoreNotFound(err)
+	}
+
+	jitsi := &v1alpha1.Jitsi{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: user.Namespace, Name: user.Spec.JitsiRef.Name}, jitsi)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	jitsiFound := err == nil && jitsi.DeletionTimestamp.IsZero()
+	if jitsiFound {
+		jitsi.SetDefaults()
+	}
+
+	if !user.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(user, accountFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		// the accounts go along with the Prosody of a deleted instance
+		if jitsiFound && len(user.Status.JID) > 0 {
+			if err := r.unregister(ctx, jitsi, user.Status.JID); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.event(user, corev1.EventTypeNormal, reasonAccountRemoved, fmt.Sprintf("account %s removed", user.Status.JID))
+		}
+		if jitsiFound && user.Spec.Moderator {
+			pods, err := r.prosodyPods(ctx, jitsi)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if err := r.syncModerators(ctx, jitsi, pods); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		controllerutil.RemoveFinalizer(user, accountFinalizer)
+		return ctrl.Result{}, r.Client.Update(ctx, user)
+	}
+
+	if controllerutil.AddFinalizer(user, accountFinalizer) {
+		if err := r.Client.Update(ctx, user); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !jitsiFound {
+		user.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonJitsiNotFound,
+			fmt.Sprintf("jitsi %s does not exist", user.Spec.JitsiRef.Name))
+		return ctrl.Result{}, r.updateStatus(ctx, user)
+	}
+
+	if !jitsi.InternalAuth() {
+		user.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonAuthNotInternal,
+			fmt.Sprintf("jitsi %s does not authenticate its users with the internal type", jitsi.Name))
+		return ctrl.Result{}, r.updateStatus(ctx, user)
+	}
+
+	secret := &corev1.Secret{}
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: user.Namespace, Name: user.Spec.Password.Name}, secret)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	password := string(secret.Data[user.Spec.Password.Key])
+	if len(password) == 0 {
+		user.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonPasswordNotFound,
+			fmt.Sprintf("key %s of secret %s is missing or empty", user.Spec.Password.Key, user.Spec.Password.Name))
+		return ctrl.Result{}, r.updateStatus(ctx, user)
+	}
+
+	jid := user.JID(jitsi)
+	if len(user.Status.JID) > 0 && user.Status.JID != jid {
+		if err := r.unregister(ctx, jitsi, user.Status.JID); err != nil {
+			user.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonSyncFailed, err.Error())
+			return ctrl.Result{}, r.updateStatus(ctx, user)
+		}
+		user.Status.Pods = nil
+		user.Status.Containers = nil
+	}
+	user.Status.JID = jid
+
+	// a new password is set on every pod, a known one only on new containers,
+	// a restarted container lost the accounts of the previous one
+	registered := map[string]bool{}
+	if user.Status.PasswordVersion == secret.ResourceVersion {
+		for _, container := range user.Status.Containers {
+			registered[container] = true
+		}
+	}
+
+	pods, err := r.prosodyPods(ctx, jitsi)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	user.Status.Pods = []string{}
+	user.Status.Containers = []string{}
+	user.Status.PasswordVersion = secret.ResourceVersion
+	for i := range pods {
+		pod := &pods[i]
+		container := prosodyContainerID(pod)
+		if !registered[container] {
+			if err := r.prosody.register(ctx, pod, jid, password); err != nil {
+				user.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonSyncFailed, err.Error())
+				return ctrl.Result{}, r.updateStatus(ctx, user)
+			}
+		}
+		user.Status.Pods = append(user.Status.Pods, pod.Name)
+		user.Status.Containers = append(user.Status.Containers, container)
+	}
+	sort.Strings(user.Status.Pods)
+	sort.Strings(user.Status.Containers)
+
+	if err := r.syncModerators(ctx, jitsi, pods); err != nil {
+		user.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, user)
+	}
+
+	if len(pods) == 0 {
+		user.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonProsodyNotReady, "no Prosody pod is ready")
+	} else if !meta.IsStatusConditionTrue(user.Status.Conditions, v1alpha1.ConditionReady) {
+		r.event(user, corev1.EventTypeNormal, reasonAccountRegistered, fmt.Sprintf("account %s registered", jid))
+		user.SetCondition(v1alpha1.ConditionReady, metav1.ConditionTrue, reasonAccountRegistered, "the account is registered on every Prosody pod")
+	}
+
+	user.Status.ObservedGeneration = user.Generation
+	return ctrl.Result{}, r.updateStatus(ctx, user)
+}
+
+// prosodyPods returns the ready Prosody pods of all the shards of the
+// instance
+func (r *JitsiUserReconciler) prosodyPods(ctx context.Context, jitsi *v1alpha1.Jitsi) ([]corev1.Pod, error) {
+	ready := []corev1.Pod{}
+	shards := jitsi.Shards()
+	for i := range shards {
+		pods := &corev1.PodList{}
+		if err := r.Client.List(ctx, pods, client.InNamespace(jitsi.Namespace), client.MatchingLabels(jitsi.ShardLabels(&shards[i], "prosody"))); err != nil {
+			return nil, fmt.Errorf("unable to list the prosody pods: %w", err)
+		}
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp.IsZero() && podReady(&pod) {
+				ready = append(ready, pod)
+			}
+		}
+	}
+	return ready, nil
+}
+
+// prosodyContainerID identifies the running prosody container of the pod,
+// which keeps the accounts until it restarts
+func prosodyContainerID(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == "prosody" {
+			return status.ContainerID
+		}
+	}
+	return string(pod.UID)
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// syncModerators writes the moderators of the instance into its Prosody
+// pods, which reload their configuration when they changed
+func (r *JitsiUserReconciler) syncModerators(ctx context.Context, jitsi *v1alpha1.Jitsi, pods []corev1.Pod) error {
+	moderators, err := moderators(ctx, r.Client, jitsi)
+	if err != nil {
+		return err
+	}
+	config := moderatorsConfig(jitsi, moderators)
+	for i := range pods {
+		if err := r.prosody.setAdmins(ctx, &pods[i], config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *JitsiUserReconciler) unregister(ctx context.Context, jitsi *v1alpha1.Jitsi, jid string) error {
+	pods, err := r.prosodyPods(ctx, jitsi)
+	if err != nil {
+		return err
+	}
+	for i := range pods {
+		if err := r.prosody.unregister(ctx, &pods[i], jid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateStatus writes the status of the user, retrying on conflicts with the
+// latest version of the object
+func (r *JitsiUserReconciler) updateStatus(ctx context.Context, user *v1alpha1.JitsiUser) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha1.JitsiUser{}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(user), latest); err != nil {
+			return err
+		}
+		latest.Status = user.Status
+		if err := r.Client.Status().Update(ctx, latest); err != nil {
+			return err
+		}
+		user.ResourceVersion = latest.ResourceVersion
+		return nil
+	})
+}
+
+func (r *JitsiUserReconciler) event(user *v1alpha1.JitsiUser, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(user, eventType, reason, message)
+}
+
+// usersOf requeues the users of the namespace of obj matching the filter
+func (r *JitsiUserReconciler) usersOf(ctx context.Context, obj client.Object, match func(user *v1alpha1.JitsiUser) bool) []reconcile.Request {
+	users := &v1alpha1.JitsiUserList{}
+	if err := r.Client.List(ctx, users, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list the users", "namespace", obj.GetNamespace())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range users.Items {
+		if match(&users.Items[i]) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&users.Items[i]),
+			})
+		}
+	}
+	return requests
+}
+
+// usersOfJitsi requeues the users of an instance when it changes
+func (r *JitsiUserReconciler) usersOfJitsi(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.usersOf(ctx, obj, func(user *v1alpha1.JitsiUser) bool {
+		return user.Spec.JitsiRef.Name == obj.GetName()
+	})
+}
+
+// usersOfProsody requeues the users of an instance when its Prosody pods
+// come and go
+func (r *JitsiUserReconciler) usersOfProsody(ctx context.Context, obj client.Object) []reconcile.Request {
+	l := obj.GetLabels()
+	if l["app.kubernetes.io/component"] != "prosody" || l["app.kubernetes.io/managed-by"] != "jitsi-operator" {
+		return nil
+	}
+	return r.usersOf(ctx, obj, func(user *v1alpha1.JitsiUser) bool {
+		return user.Spec.JitsiRef.Name == l["app.kubernetes.io/instance"]
+	})
+}
+
+// usersOfSecret requeues the users whose password a Secret holds
+func (r *JitsiUserReconciler) usersOfSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.usersOf(ctx, obj, func(user *v1alpha1.JitsiUser) bool {
+		return user.Spec.Password.Name == obj.GetName()
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JitsiUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	prosody, err := newProsodyExecutor(r.Config)
+	if err != nil {
+		return err
+	}
+	r.prosody = prosody
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.JitsiUser{}).
+		Watches(&v1alpha1.Jitsi{}, handler.EnqueueRequestsFromMapFunc(r.usersOfJitsi)).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.usersOfProsody)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.usersOfSecret)).
+		Complete(r)
+}
+
+// moderators returns the JIDs of the moderators of the instance, made admins
+// of its Prosody at runtime
+func moderators(ctx context.Context, c client.Client, jitsi *v1alpha1.Jitsi) ([]string, error) {
+	users := &v1alpha1.JitsiUserList{}
+	if err := c.List(ctx, users, client.InNamespace(jitsi.Namespace)); err != nil {
+		return nil, fmt.Errorf("unable to list the users: %w", err)
+	}
+
+	jids := []string{}
+	for i := range users.Items {
+		user := &users.Items[i]
+		if user.Spec.JitsiRef.Name == jitsi.Name && user.Spec.Moderator && user.DeletionTimestamp.IsZero() {
+			jids = append(jids, user.JID(jitsi))
+		}
+	}
+	sort.Strings(jids)
+	return jids, nil
+}
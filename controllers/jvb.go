@@ -1,18 +1,19 @@
 package controllers
 
 import (
-	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
 
 	"github.com/presslabs/controller-util/pkg/rand"
 	"github.com/presslabs/controller-util/pkg/syncer"
 	v1 "k8s.io/api/apps/v1"
-	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -24,7 +25,10 @@ var secretsVar = []string{
 	"JIBRI_RECORDER_PASSWORD",
 }
 
-func NewJitsiSecretSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+// NewJitsiSecretSyncer generates the internal passwords missing from the
+// secret, or all of them when rotate is set, recording an event on jitsi when
+// it does if recorder is set
+func NewJitsiSecretSyncer(jitsi *v1alpha1.Jitsi, rotate bool, c client.Client, recorder record.EventRecorder) syncer.Interface {
 	sec := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      jitsi.Name,
@@ -39,36 +43,46 @@ func NewJitsiSecretSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interfa
 			sec.Data = make(map[string][]byte, 5)
 		}
 
-		for _, secretVar := range secretsVar {
-			if len(sec.Data[secretVar]) == 0 {
+		vars := secretVars(jitsi)
+		generated := []string{}
+		for _, secretVar := range vars {
+			if len(sec.Data[secretVar]) == 0 || rotate {
 				random, err := rand.AlphaNumericString(32)
 				if err != nil {
 					return err
 				}
 
 				sec.Data[secretVar] = []byte(random)
+				generated = append(generated, secretVar)
 			}
 		}
 
+		if len(generated) > 0 && recorder != nil {
+			recorder.Eventf(jitsi, corev1.EventTypeNormal, "SecretGenerated",
+				"generated %s in secret %s", strings.Join(generated, ", "), sec.Name)
+		}
+
 		return nil
 	})
 
 }
 
-func injectJVBAffinity(jitsi *v1alpha1.Jitsi, pod *corev1.PodSpec) {
-	if jitsi.Spec.JVB.DisableDefaultAffinity {
-		pod.Affinity = &jitsi.Spec.JVB.Affinity
+// injectJVBAffinity spreads the bridges matching selector over the nodes,
+// preferably away from Jibri, unless jvb disables the default affinity.
+// Bridges binding a host port need a node each, others only prefer it.
+func injectJVBAffinity(jitsi *v1alpha1.Jitsi, jvb *v1alpha1.JVB, selector labels.Set, pod *corev1.PodSpec) {
+	if jvb.DisableDefaultAffinity {
+		pod.Affinity = &jvb.Affinity
 	} else {
+		spread := corev1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: selector,
+			},
+			TopologyKey: "kubernetes.io/hostname",
+		}
 		pod.Affinity = &corev1.Affinity{
 			PodAntiAffinity: &corev1.PodAntiAffinity{
-				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
-					{
-						LabelSelector: &metav1.LabelSelector{
-							MatchLabels: jitsi.ComponentLabels("jvb"),
-						},
-						TopologyKey: "kubernetes.io/hostname",
-					},
-				},
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{spread},
 				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
 					{
 						Weight: 100,
@@ -82,13 +96,21 @@ func injectJVBAffinity(jitsi *v1alpha1.Jitsi, pod *corev1.PodSpec) {
 				},
 			},
 		}
-		MergeAffinities(pod.Affinity, jitsi.Spec.JVB.Affinity)
+		if jvb.ServiceExposed() {
+			anti := pod.Affinity.PodAntiAffinity
+			anti.RequiredDuringSchedulingIgnoredDuringExecution = nil
+			anti.PreferredDuringSchedulingIgnoredDuringExecution = append(anti.PreferredDuringSchedulingIgnoredDuringExecution,
+				corev1.WeightedPodAffinityTerm{Weight: 50, PodAffinityTerm: spread})
+		}
+		MergeAffinities(pod.Affinity, jvb.Affinity)
 	}
 
 }
 
-func JVBPodTemplateSpec(jitsi *v1alpha1.Jitsi, podSpec *corev1.PodTemplateSpec) {
-	envVars := append(jitsi.EnvVars(JvbVariables),
+// JVBPodTemplateSpec sets up the bridges described by jvb joining the
+// brewery of the shard
+func JVBPodTemplateSpec(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, jvb *v1alpha1.JVB, podSpec *corev1.PodTemplateSpec) {
+	envVars := append(jitsi.ShardEnvVars(shard, &jvb.ComponentEnv, v1alpha1.JvbVariables),
 		corev1.EnvVar{
 			Name: "LOCAL_ADDRESS",
 			ValueFrom: &corev1.EnvVarSource{
@@ -102,13 +124,12 @@ func JVBPodTemplateSpec(jitsi *v1alpha1.Jitsi, podSpec *corev1.PodTemplateSpec)
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: jitsi.Name,
+						Name: jitsi.SecretName(),
 					},
 					Key: "JVB_AUTH_PASSWORD",
 				},
 			},
 		},
-		// TODO options to manage host IP through vars or stun servers
 		corev1.EnvVar{
 			Name: "JVB_OCTO_RELAY_ID",
 			ValueFrom: &corev1.EnvVarSource{
@@ -127,16 +148,37 @@ func JVBPodTemplateSpec(jitsi *v1alpha1.Jitsi, podSpec *corev1.PodTemplateSpec)
 		},
 	)
 
+	envVars = append(envVars, upstreamEnvVars(jitsi)...)
+
+	for i := range envVars {
+		if envVars[i].Name == "JVB_PORT" {
+			envVars[i].Value = strconv.FormatInt(int64(*jvb.Ports.UDP), 10)
+		}
+	}
+
+	if jvb.TCP != nil {
+		envVars = append(envVars,
+			corev1.EnvVar{Name: "JVB_TCP_ENABLED", Value: "1"},
+			corev1.EnvVar{Name: "JVB_TCP_PORT", Value: strconv.FormatInt(int64(*jvb.Ports.TCP), 10)},
+		)
+		if jvb.TCP.AdvertisedPort != nil {
+			envVars = append(envVars, corev1.EnvVar{Name: "JVB_TCP_MAPPED_PORT", Value: strconv.FormatInt(int64(*jvb.TCP.AdvertisedPort), 10)})
+		}
+		if jvb.TCP.SSLTCP != nil {
+			envVars = append(envVars, corev1.EnvVar{Name: "JVB_TCP_SSLTCP", Value: strconv.FormatBool(*jvb.TCP.SSLTCP)})
+		}
+	}
+
 	jvbContainer := corev1.Container{
 		Name:            "jvb",
-		Image:           jitsi.Spec.JVB.Image,
-		ImagePullPolicy: jitsi.Spec.JVB.ImagePullPolicy,
+		Image:           jvb.Image,
+		ImagePullPolicy: jvb.ImagePullPolicy,
 		Env:             envVars,
 		Ports: []corev1.ContainerPort{
 			{
 				Name:          "rtp-udp",
-				ContainerPort: *jitsi.Spec.JVB.Ports.UDP,
-				HostPort:      *jitsi.Spec.JVB.Ports.UDP,
+				ContainerPort: *jvb.Ports.UDP,
+				HostPort:      *jvb.Ports.UDP,
 				Protocol:      corev1.ProtocolUDP,
 			},
 			{
@@ -155,7 +197,16 @@ func JVBPodTemplateSpec(jitsi *v1alpha1.Jitsi, podSpec *corev1.PodTemplateSpec)
 		},
 	}
 
-	if jitsi.Spec.JVB.GracefulShutdown {
+	if jvb.TCP != nil {
+		jvbContainer.Ports = append(jvbContainer.Ports, corev1.ContainerPort{
+			Name:          "rtp-tcp",
+			ContainerPort: *jvb.Ports.TCP,
+			HostPort:      *jvb.Ports.TCP,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+
+	if jvb.GracefulShutdown {
 		gracePeriod := int64(14400)
 		podSpec.Spec.TerminationGracePeriodSeconds = &gracePeriod
 		jvbContainer.Lifecycle = &corev1.Lifecycle{
@@ -169,20 +220,27 @@ func JVBPodTemplateSpec(jitsi *v1alpha1.Jitsi, podSpec *corev1.PodTemplateSpec)
 		}
 	}
 
-	if jitsi.Spec.JVB.Resources != nil {
-		jvbContainer.Resources = *jitsi.Spec.JVB.Resources
+	if jvb.Resources != nil {
+		jvbContainer.Resources = *jvb.Resources
 	}
 
 	podSpec.Spec.Containers = []corev1.Container{jvbContainer}
+	injectSecretsVersion(jitsi, "jvb", podSpec)
+	// set up by injectPublicIP and injectExposure
+	podSpec.Spec.InitContainers = nil
+	podSpec.Spec.Volumes = nil
 }
 
-func NewJVBDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
-	dep := jitsi.JVBDeployment()
+func NewJVBDeploymentSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
+	dep := jitsi.JVBDeployment(shard)
 
 	return syncer.NewObjectSyncer("Deployment", jitsi, &dep, c, func() error {
-		dep.Labels = jitsi.ComponentLabels("jvb")
+		dep.Labels = jitsi.ShardLabels(shard, "jvb")
 
-		JVBPodTemplateSpec(jitsi, &dep.Spec.Template)
+		JVBPodTemplateSpec(jitsi, shard, &jitsi.Spec.JVB, &dep.Spec.Template)
+		injectPublicIP(jitsi.Spec.JVB.PublicIP, jvbAddressesConfigMapName(jitsi), &dep.Spec.Template)
+		injectExposure(&jitsi.Spec.JVB, jvbBridgesConfigMapName(jitsi), &dep.Spec.Template)
+		injectContentVersion(jitsi, "jvb", holdBridgesContent(jitsi), &dep.Spec.Template)
 
 		dep.Spec.Template.Labels = dep.Labels
 
@@ -190,69 +248,46 @@ func NewJVBDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Inter
 			MatchLabels: dep.Labels,
 		}
 
-		injectJVBAffinity(jitsi, &dep.Spec.Template.Spec)
+		injectJVBAffinity(jitsi, &jitsi.Spec.JVB, jitsi.ComponentLabels("jvb"), &dep.Spec.Template.Spec)
 
-		dep.Spec.Strategy = v1.DeploymentStrategy{
-			Type: v1.RollingUpdateDeploymentStrategyType,
-			RollingUpdate: &v1.RollingUpdateDeployment{
-				MaxUnavailable: &intstr.IntOrString{Type: intstr.String, StrVal: "50%"},
-				MaxSurge:       &intstr.IntOrString{Type: intstr.Int, IntVal: 0},
-			},
+		dep.Spec.Strategy = jvbDeploymentStrategy()
+
+		strategy := jitsi.JVBStrategy(shard)
+		dep.Spec.Replicas = autoscaledReplicas(dep.Spec.Replicas, strategy)
+		if strategy.OperatorAutoscaled() {
+			setEnvVar(&dep.Spec.Template.Spec.Containers[0], "SHUTDOWN_REST_ENABLED", "1")
 		}
-		dep.Spec.Replicas = jitsi.Spec.JVB.Strategy.Replicas
 		// dep.Spec.ProgressDeadlineSeconds =
-		return nil
+		return applyPodOverrides(&jitsi.Spec.JVB.PodOverrides, jitsi.Spec.JVB.Env, &dep.Spec.Template)
 	})
 
 }
 
-func NewJVBHPASyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
-	obj := jitsi.JVBHPA()
+func NewJVBHPASyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
+	obj := jitsi.JVBHPA(shard)
+	strategy := jitsi.JVBStrategy(shard)
 
 	return syncer.NewObjectSyncer("HorizontalPodAutoscaler", jitsi, &obj, c, func() error {
-		obj.Labels = jitsi.ComponentLabels("jvb")
-
-		obj.Annotations = make(map[string]string)
-		obj.Annotations["metric-config.pods.jitsi-stress-level.json-path/json-key"] = "$.stress_level"
-		obj.Annotations["metric-config.pods.jitsi-stress-level.json-path/path"] = "/colibri/stats"
-		obj.Annotations["metric-config.pods.jitsi-stress-level.json-path/port"] = "8080"
-
-		obj.Spec = autoscalingv2.HorizontalPodAutoscalerSpec{
-			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
-				APIVersion: "apps/v1",
-				Kind:       "Deployment",
-				Name:       fmt.Sprintf("%s-jvb", jitsi.Name),
-			},
-			MinReplicas: jitsi.Spec.JVB.Strategy.Replicas,
-			MaxReplicas: jitsi.Spec.JVB.Strategy.MaxReplicas,
-			Metrics: []autoscalingv2.MetricSpec{
-				{
-					Type: autoscalingv2.PodsMetricSourceType,
-					Pods: &autoscalingv2.PodsMetricSource{
-						Metric: autoscalingv2.MetricIdentifier{
-							Name: "jitsi-stress-level",
-						},
-						Target: autoscalingv2.MetricTarget{
-							Type:         autoscalingv2.AverageValueMetricType,
-							AverageValue: resource.NewMilliQuantity(800, resource.DecimalSI),
-						},
-					},
-				},
-			},
-		}
+		obj.Labels = jitsi.ShardLabels(shard, "jvb")
+
+		obj.Annotations = jvbHPAAnnotations(strategy)
+		obj.Spec = jvbHPASpec(jitsi.ShardName(shard, "jvb"), jitsi.ShardLabels(shard, "jvb"), strategy)
 
 		return nil
 	})
 
 }
 
-func NewJVBDaemonSetSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
-	dep := jitsi.JVBDaemonSet()
+func NewJVBDaemonSetSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
+	dep := jitsi.JVBDaemonSet(shard)
 
 	return syncer.NewObjectSyncer("DaemonSet", jitsi, &dep, c, func() error {
-		dep.Labels = jitsi.ComponentLabels("jvb")
+		dep.Labels = jitsi.ShardLabels(shard, "jvb")
 
-		JVBPodTemplateSpec(jitsi, &dep.Spec.Template)
+		JVBPodTemplateSpec(jitsi, shard, &jitsi.Spec.JVB, &dep.Spec.Template)
+		injectPublicIP(jitsi.Spec.JVB.PublicIP, jvbAddressesConfigMapName(jitsi), &dep.Spec.Template)
+		injectExposure(&jitsi.Spec.JVB, jvbBridgesConfigMapName(jitsi), &dep.Spec.Template)
+		injectContentVersion(jitsi, "jvb", holdBridgesContent(jitsi), &dep.Spec.Template)
 
 		dep.Spec.Template.Labels = dep.Labels
 
@@ -260,9 +295,20 @@ func NewJVBDaemonSetSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interf
 			MatchLabels: dep.Labels,
 		}
 
-		injectJVBAffinity(jitsi, &dep.Spec.Template.Spec)
+		injectJVBAffinity(jitsi, &jitsi.Spec.JVB, jitsi.ComponentLabels("jvb"), &dep.Spec.Template.Spec)
 
-		return nil
+		return applyPodOverrides(&jitsi.Spec.JVB.PodOverrides, jitsi.Spec.JVB.Env, &dep.Spec.Template)
 	})
 
 }
+
+// jvbDeploymentStrategy never surges bridges, which bind host ports
+func jvbDeploymentStrategy() v1.DeploymentStrategy {
+	return v1.DeploymentStrategy{
+		Type: v1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &v1.RollingUpdateDeployment{
+			MaxUnavailable: &intstr.IntOrString{Type: intstr.String, StrVal: "50%"},
+			MaxSurge:       &intstr.IntOrString{Type: intstr.Int, IntVal: 0},
+		},
+	}
+}
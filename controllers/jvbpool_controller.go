@@ -0,0 +1,432 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/presslabs/controller-util/pkg/syncer"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	reasonJitsiNotFound   = "JitsiNotFound"
+	reasonJitsiDeleting   = "JitsiDeleting"
+	reasonJitsiUpgrading  = "JitsiUpgrading"
+	reasonBridgesReady    = "BridgesReady"
+	reasonBridgesNotReady = "BridgesNotReady"
+)
+
+// JVBPoolReconciler reconciles a JVBPool object
+type JVBPoolReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads the bridges uncached, to allocate their ports
+	APIReader client.Reader
+}
+
+//+kubebuilder:rbac:groups=apps.jit.si,resources=jvbpools,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps.jit.si,resources=jvbpools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=apps.jit.si,resources=jvbpools/finalizers,verbs=update
+
+// Reconcile runs the bridges of a pool alongside those of the instance it
+// references. The bridges of a pool are drained along with those of their
+// instance, by its teardown.
+func (r *JVBPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("jvbpool", req.NamespacedName)
+
+	pool := &v1alpha1.JVBPool{}
+	if err := r.Client.Get(ctx, req.NamespacedName, pool); err != nil {
+		return ctrl.Result{}, ignoreNotFound(err)
+	}
+
+	if !pool.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	jitsi := &v1alpha1.Jitsi{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: pool.Namespace, Name: pool.Spec.JitsiRef.Name}, jitsi)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil && !jitsi.DeletionTimestamp.IsZero() {
+		// the teardown of the instance deletes the bridges once their
+		// conferences ended, the pool only reports it
+		message := fmt.Sprintf("jitsi %s is being deleted", pool.Spec.JitsiRef.Name)
+		pool.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonJitsiDeleting, message)
+		return ctrl.Result{}, r.updateStatus(ctx, pool)
+	}
+	if err != nil {
+		message := fmt.Sprintf("jitsi %s does not exist", pool.Spec.JitsiRef.Name)
+		if err := r.deleteUndesired(ctx, pool, nil); err != nil {
+			return ctrl.Result{}, err
+		}
+		pool.Status.Replicas = 0
+		pool.Status.ReadyReplicas = 0
+		pool.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonJitsiNotFound, message)
+		pool.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonJitsiNotFound, message)
+		return ctrl.Result{}, r.updateStatus(ctx, pool)
+	}
+
+	jitsi.SetDefaults()
+	pool.SetDefaults(jitsi)
+
+	shard, err := pool.Shard(jitsi)
+	if err == nil {
+		err = pool.Validate()
+	}
+	if err != nil {
+		r.event(pool, corev1.EventTypeWarning, reasonInvalidSpec, err.Error())
+		pool.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonInvalidSpec, err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, pool)
+	}
+
+	// the bridges of the pool follow the operator upgrade of their instance
+	if upgradePending(jitsi) {
+		pool.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionFalse, reasonJitsiUpgrading,
+			fmt.Sprintf("waiting for the upgrade of jitsi %s", jitsi.Name))
+		return ctrl.Result{RequeueAfter: upgradeRetryInterval}, r.updateStatus(ctx, pool)
+	}
+
+	syncers := jvbPoolSyncers(pool, jitsi, shard, r.Client)
+
+	nodes, err := listNodes(ctx, r.Client, pool.Spec.PublicIP)
+	if err != nil {
+		pool.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, pool)})
+	}
+	if nodes != nil {
+		// synced ahead of the bridges reading it
+		syncers = append([]syncer.Interface{NewJVBPoolAddressesConfigMapSyncer(pool, jitsi, nodes, r.Client)}, syncers...)
+	}
+
+	addressesPending := false
+	if pool.Spec.ServiceExposed() {
+		exposure, pending, err := bridgeExposure(ctx, r.Client, r.APIReader, pool, &pool.Spec.JVB, pool.Labels(jitsi), jvbPoolBridgesConfigMapName(pool))
+		if err != nil {
+			pool.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, pool)})
+		}
+		syncers = append(syncers, exposure...)
+		addressesPending = pending
+	}
+
+	if err := r.deleteUndesired(ctx, pool, syncers); err != nil {
+		pool.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+		return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, pool)})
+	}
+
+	for _, s := range syncers {
+		if err := syncer.Sync(ctx, s, r.Recorder); err != nil {
+			pool.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonSyncFailed, err.Error())
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{err, r.updateStatus(ctx, pool)})
+		}
+	}
+	pool.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionFalse, reasonSyncSucceeded, "all bridges are in sync")
+
+	if err := r.refreshStatus(ctx, pool); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	pool.Status.ObservedGeneration = pool.Generation
+	if err := r.updateStatus(ctx, pool); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	result := ctrl.Result{}
+	if addressesPending {
+		result.RequeueAfter = bridgeAddressInterval
+	}
+	if !pool.Spec.Strategy.OperatorAutoscaled() {
+		return result, nil
+	}
+
+	dep, _, _ := jvbPoolWorkloads(pool)
+	if err := autoscaleBridges(ctx, r.Client, dep, pool.Spec.Strategy, func(reason, message string) {
+		r.event(pool, corev1.EventTypeNormal, reason, message)
+	}); err != nil {
+		r.event(pool, corev1.EventTypeWarning, reasonAutoscaleFailed, err.Error())
+		r.Log.Error(err, "unable to autoscale the bridges", "deployment", dep.Name)
+	}
+	if result.RequeueAfter == 0 {
+		result.RequeueAfter = bridgeAutoscaleInterval
+	}
+	return result, nil
+}
+
+// jvbPoolWorkloads returns the objects the bridges of the pool may run with,
+// named after the pool
+func jvbPoolWorkloads(pool *v1alpha1.JVBPool) (*appsv1.Deployment, *appsv1.DaemonSet, *autoscalingv2.HorizontalPodAutoscaler) {
+	meta := metav1.ObjectMeta{
+		Name:      pool.Name,
+		Namespace: pool.Namespace,
+	}
+
+	return &appsv1.Deployment{ObjectMeta: *meta.DeepCopy()},
+		&appsv1.DaemonSet{ObjectMeta: *meta.DeepCopy()},
+		&autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: *meta.DeepCopy()}
+}
+
+func jvbPoolSyncers(pool *v1alpha1.JVBPool, jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) []syncer.Interface {
+	switch pool.Spec.Strategy.Type {
+	case v1alpha1.JVBStrategyAutoScaled:
+		switch pool.Spec.Strategy.AutoscalerSettings().Backend {
+		case v1alpha1.JVBAutoscalerKEDA:
+			return []syncer.Interface{
+				NewJVBPoolDeploymentSyncer(pool, jitsi, shard, c),
+				NewJVBPoolScaledObjectSyncer(pool, jitsi, c),
+			}
+		case v1alpha1.JVBAutoscalerOperator:
+			return []syncer.Interface{NewJVBPoolDeploymentSyncer(pool, jitsi, shard, c)}
+		}
+		return []syncer.Interface{
+			NewJVBPoolDeploymentSyncer(pool, jitsi, shard, c),
+			NewJVBPoolHPASyncer(pool, jitsi, c),
+		}
+	case v1alpha1.JVBStrategyDaemon:
+		return []syncer.Interface{NewJVBPoolDaemonSetSyncer(pool, jitsi, shard, c)}
+	default:
+		return []syncer.Interface{NewJVBPoolDeploymentSyncer(pool, jitsi, shard, c)}
+	}
+}
+
+// jvbPoolPodTemplateSpec sets up the bridges of the pool on the nodes it
+// selects
+func jvbPoolPodTemplateSpec(pool *v1alpha1.JVBPool, jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, template *corev1.PodTemplateSpec) {
+	JVBPodTemplateSpec(jitsi, shard, &pool.Spec.JVB, template)
+	injectPublicIP(pool.Spec.PublicIP, jvbPoolAddressesConfigMapName(pool), template)
+	injectExposure(&pool.Spec.JVB, jvbPoolBridgesConfigMapName(pool), template)
+
+	template.Labels = pool.Labels(jitsi)
+
+	injectJVBAffinity(jitsi, &pool.Spec.JVB, pool.Labels(jitsi), &template.Spec)
+}
+
+func NewJVBPoolDeploymentSyncer(pool *v1alpha1.JVBPool, jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
+	dep, _, _ := jvbPoolWorkloads(pool)
+
+	return syncer.NewObjectSyncer("Deployment", pool, dep, c, func() error {
+		dep.Labels = pool.Labels(jitsi)
+		dep.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: pool.Labels(jitsi),
+		}
+
+		jvbPoolPodTemplateSpec(pool, jitsi, shard, &dep.Spec.Template)
+
+		dep.Spec.Strategy = jvbDeploymentStrategy()
+		dep.Spec.Replicas = autoscaledReplicas(dep.Spec.Replicas, pool.Spec.Strategy)
+		if pool.Spec.Strategy.OperatorAutoscaled() {
+			setEnvVar(&dep.Spec.Template.Spec.Containers[0], "SHUTDOWN_REST_ENABLED", "1")
+		}
+		return applyPodOverrides(&pool.Spec.PodOverrides, pool.Spec.Env, &dep.Spec.Template)
+	})
+}
+
+func NewJVBPoolHPASyncer(pool *v1alpha1.JVBPool, jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	_, _, obj := jvbPoolWorkloads(pool)
+
+	return syncer.NewObjectSyncer("HorizontalPodAutoscaler", pool, obj, c, func() error {
+		obj.Labels = pool.Labels(jitsi)
+		obj.Annotations = jvbHPAAnnotations(pool.Spec.Strategy)
+		obj.Spec = jvbHPASpec(pool.Name, pool.Labels(jitsi), pool.Spec.Strategy)
+
+		return nil
+	})
+}
+
+func NewJVBPoolScaledObjectSyncer(pool *v1alpha1.JVBPool, jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	obj := jvbScaledObject(pool.Namespace, pool.Name)
+
+	return syncer.NewObjectSyncer("ScaledObject", pool, obj, c, func() error {
+		obj.SetLabels(pool.Labels(jitsi))
+
+		return setJVBScaledObjectSpec(obj, pool.Labels(jitsi), pool.Spec.Strategy)
+	})
+}
+
+func NewJVBPoolDaemonSetSyncer(pool *v1alpha1.JVBPool, jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
+	_, ds, _ := jvbPoolWorkloads(pool)
+
+	return syncer.NewObjectSyncer("DaemonSet", pool, ds, c, func() error {
+		ds.Labels = pool.Labels(jitsi)
+		ds.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: pool.Labels(jitsi),
+		}
+
+		jvbPoolPodTemplateSpec(pool, jitsi, shard, &ds.Spec.Template)
+
+		return applyPodOverrides(&pool.Spec.PodOverrides, pool.Spec.Env, &ds.Spec.Template)
+	})
+}
+
+// deleteUndesired deletes the workloads of the pool the desired syncers do
+// not produce, e.g. the Deployment once the strategy switched to daemonset
+func (r *JVBPoolReconciler) deleteUndesired(ctx context.Context, pool *v1alpha1.JVBPool, desired []syncer.Interface) error {
+	dep, ds, hpa := jvbPoolWorkloads(pool)
+	workloads := []struct {
+		kind string
+		obj  client.Object
+	}{
+		{"HorizontalPodAutoscaler", hpa},
+		{"ScaledObject", jvbScaledObject(pool.Namespace, pool.Name)},
+		{"Deployment", dep},
+		{"DaemonSet", ds},
+		{"ConfigMap", &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      jvbPoolAddressesConfigMapName(pool),
+			Namespace: pool.Namespace,
+		}}},
+		{"ConfigMap", &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      jvbPoolBridgesConfigMapName(pool),
+			Namespace: pool.Namespace,
+		}}},
+	}
+
+	keep := map[string]bool{}
+	for _, s := range desired {
+		obj := s.Object().(client.Object)
+		gvk, err := apiutil.GVKForObject(obj, r.Client.Scheme())
+		if err != nil {
+			return err
+		}
+		keep[gvk.Kind+"/"+obj.GetName()] = true
+	}
+
+	errs := []error{}
+	for _, workload := range workloads {
+		obj := workload.obj
+		if keep[workload.kind+"/"+obj.GetName()] {
+			continue
+		}
+
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			// scaled objects are only known to clusters running KEDA
+			if !apierrs.IsNotFound(err) && !meta.IsNoMatchError(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if !metav1.IsControlledBy(obj, pool) || obj.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		if err := ignoreNotFound(r.Client.Delete(ctx, obj)); err != nil {
+			r.event(pool, corev1.EventTypeWarning, reasonDeleteFailed, fmt.Sprintf("failed to delete %s %s: %s", workload.kind, obj.GetName(), err))
+			errs = append(errs, err)
+			continue
+		}
+		r.event(pool, corev1.EventTypeNormal, reasonGarbageCollected, fmt.Sprintf("deleted %s %s, no longer desired", workload.kind, obj.GetName()))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// refreshStatus reports the replicas of the workload of the pool and the
+// Ready and Progressing conditions derived from them
+func (r *JVBPoolReconciler) refreshStatus(ctx context.Context, pool *v1alpha1.JVBPool) error {
+	dep, ds, _ := jvbPoolWorkloads(pool)
+
+	if pool.Spec.Strategy.Type == v1alpha1.JVBStrategyDaemon {
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(ds), ds); err != nil {
+			return err
+		}
+		pool.Status.Replicas = ds.Status.DesiredNumberScheduled
+		pool.Status.ReadyReplicas = ds.Status.NumberReady
+	} else {
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(dep), dep); err != nil {
+			return err
+		}
+		pool.Status.Replicas = 0
+		if dep.Spec.Replicas != nil {
+			pool.Status.Replicas = *dep.Spec.Replicas
+		}
+		pool.Status.ReadyReplicas = dep.Status.ReadyReplicas
+	}
+
+	if pool.Status.ReadyReplicas >= pool.Status.Replicas {
+		pool.SetCondition(v1alpha1.ConditionReady, metav1.ConditionTrue, reasonBridgesReady, "all bridges are ready")
+		pool.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionFalse, reasonBridgesReady, "all bridges are ready")
+	} else {
+		message := fmt.Sprintf("waiting for bridges (%d/%d)", pool.Status.ReadyReplicas, pool.Status.Replicas)
+		pool.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonBridgesNotReady, message)
+		pool.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue, reasonBridgesNotReady, message)
+	}
+
+	return nil
+}
+
+// updateStatus writes the status of pool, retrying on conflicts with the
+// latest version of the object
+func (r *JVBPoolReconciler) updateStatus(ctx context.Context, pool *v1alpha1.JVBPool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha1.JVBPool{}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(pool), latest); err != nil {
+			return err
+		}
+		latest.Status = pool.Status
+		if err := r.Client.Status().Update(ctx, latest); err != nil {
+			return err
+		}
+		pool.ResourceVersion = latest.ResourceVersion
+		return nil
+	})
+}
+
+func (r *JVBPoolReconciler) event(pool *v1alpha1.JVBPool, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(pool, eventType, reason, message)
+}
+
+// poolsOfJitsi requeues the pools referencing a Jitsi when it changes
+func (r *JVBPoolReconciler) poolsOfJitsi(ctx context.Context, obj client.Object) []reconcile.Request {
+	pools := &v1alpha1.JVBPoolList{}
+	if err := r.Client.List(ctx, pools, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list the pools of jitsi", "jitsi", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range pools.Items {
+		if pools.Items[i].Spec.JitsiRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&pools.Items[i]),
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JVBPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.JVBPool{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.DaemonSet{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&v1alpha1.Jitsi{}, handler.EnqueueRequestsFromMapFunc(r.poolsOfJitsi)).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(poolOfBridge), builder.WithPredicates(bridgePodsChanged)).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.poolsOfNode), builder.WithPredicates(nodeAddressesChanged)).
+		Complete(r)
+}
@@ -22,9 +22,19 @@ func NewJVBPodMonitorSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Inter
 	return syncer.NewObjectSyncer("PodMonitor", jitsi, mon, c, func() error {
 		mon.Labels = jitsi.ComponentLabels("jvb")
 
+		// scrape the bridges of the pools as well, the copied labels tell
+		// the bridges of each workload apart for the autoscalers
 		mon.Spec.Selector = metav1.LabelSelector{
-			MatchLabels: jitsi.ComponentLabels("jvb"),
+			MatchLabels: jitsi.Labels(),
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{
+					Key:      "app.kubernetes.io/component",
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   []string{"jvb", "jvb-pool"},
+				},
+			},
 		}
+		mon.Spec.PodTargetLabels = jvbMetricLabels
 		mon.Spec.PodMetricsEndpoints = []monitoringv1.PodMetricsEndpoint{
 			{
 				Port: "metrics",
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// podLabelsAnnotation and podAnnotationsAnnotation list the labels and
+// annotations set from podLabels and podAnnotations, to remove them once
+// dropped from the spec
+const (
+	podLabelsAnnotation      = "apps.jit.si/pod-labels"
+	podAnnotationsAnnotation = "apps.jit.si/pod-annotations"
+)
+
+// mergeOwned copies current, the templates share their labels with the
+// selectors, removes the keys applied last time and adds values, the keys
+// already set taking precedence. It returns the keys applied this time.
+func mergeOwned(current map[string]string, values map[string]string, previous string) (map[string]string, string) {
+	merged := map[string]string{}
+	for key, value := range current {
+		merged[key] = value
+	}
+	if len(previous) > 0 {
+		for _, key := range strings.Split(previous, ",") {
+			delete(merged, key)
+		}
+	}
+
+	applied := []string{}
+	for key, value := range values {
+		if _, set := merged[key]; !set {
+			merged[key] = value
+			applied = append(applied, key)
+		}
+	}
+	sort.Strings(applied)
+
+	return merged, strings.Join(applied, ",")
+}
+
+// applyPodOverrides customizes the template built by a syncer, whose first
+// container is the one of the component, and sets the env of the component.
+// The syncers rebuild the containers, volumes and init containers on every
+// reconciliation, the overrides are applied on top of them. The other fields
+// are kept from the live object, which is why the patches must be idempotent.
+func applyPodOverrides(overrides *v1alpha1.PodOverrides, env []corev1.EnvVar, template *corev1.PodTemplateSpec) error {
+	previousLabels := template.Annotations[podLabelsAnnotation]
+	previousAnnotations := template.Annotations[podAnnotationsAnnotation]
+	delete(template.Annotations, podLabelsAnnotation)
+	delete(template.Annotations, podAnnotationsAnnotation)
+
+	var labels, annotations string
+	template.Labels, labels = mergeOwned(template.Labels, overrides.PodLabels, previousLabels)
+	template.Annotations, annotations = mergeOwned(template.Annotations, overrides.PodAnnotations, previousAnnotations)
+	if len(labels) > 0 {
+		template.Annotations[podLabelsAnnotation] = labels
+	}
+	if len(annotations) > 0 {
+		template.Annotations[podAnnotationsAnnotation] = annotations
+	}
+
+	pod := &template.Spec
+	pod.NodeSelector = overrides.NodeSelector
+	pod.Tolerations = overrides.Tolerations
+	pod.PriorityClassName = overrides.PriorityClassName
+	pod.TopologySpreadConstraints = overrides.TopologySpreadConstraints
+	pod.SecurityContext = overrides.PodSecurityContext
+	pod.Volumes = append(pod.Volumes, overrides.ExtraVolumes...)
+	pod.InitContainers = append(pod.InitContainers, overrides.ExtraInitContainers...)
+
+	if len(pod.Containers) > 0 {
+		container := &pod.Containers[0]
+		if overrides.SecurityContext != nil {
+			container.SecurityContext = overrides.SecurityContext
+		}
+		for _, envVar := range env {
+			replaced := false
+			for i := range container.Env {
+				if container.Env[i].Name == envVar.Name {
+					container.Env[i] = envVar
+					replaced = true
+				}
+			}
+			if !replaced {
+				container.Env = append(container.Env, envVar)
+			}
+		}
+		container.EnvFrom = append(container.EnvFrom, overrides.EnvFrom...)
+		container.VolumeMounts = append(container.VolumeMounts, overrides.ExtraVolumeMounts...)
+	}
+	pod.Containers = append(pod.Containers, overrides.Sidecars...)
+
+	for i := range overrides.Patches {
+		if err := applyPodPatch(&overrides.Patches[i], template); err != nil {
+			return fmt.Errorf("unable to apply patch %d of the pod template: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func applyPodPatch(patch *v1alpha1.PodPatch, template *corev1.PodTemplateSpec) error {
+	data, err := patch.JSON()
+	if err != nil {
+		return err
+	}
+
+	original, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch patch.Type {
+	case v1alpha1.PodPatchJSON:
+		operations, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return err
+		}
+		patched, err = operations.Apply(original)
+		if err != nil {
+			return err
+		}
+	default:
+		patched, err = strategicpatch.StrategicMergePatch(original, data, corev1.PodTemplateSpec{})
+		if err != nil {
+			return err
+		}
+	}
+
+	result := corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return err
+	}
+	*template = result
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/diff"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// TestPodPatchesStable reconciles twice and checks the patches give the same
+// template, on top of the fields the syncers keep from the live object
+func TestPodPatchesStable(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	jitsi := &v1alpha1.Jitsi{
+		ObjectMeta: metav1.ObjectMeta{Name: "meet", Namespace: "default", UID: "meet"},
+		Spec:       v1alpha1.JitsiSpec{Domain: "meet.example.com"},
+	}
+	jitsi.Spec.Jicofo.Patches = []v1alpha1.PodPatch{
+		{
+			Type:  v1alpha1.PodPatchStrategicMerge,
+			Patch: "spec:\n  imagePullSecrets:\n  - name: registry\n",
+		},
+		{
+			Type:  v1alpha1.PodPatchJSON,
+			Patch: "- op: add\n  path: /spec/hostAliases\n  value: [{ip: 10.0.0.10, hostnames: [turn.internal]}]\n- op: add\n  path: /spec/enableServiceLinks\n  value: false\n",
+		},
+	}
+	jitsi.SetDefaults()
+
+	ctx := context.Background()
+	templates := []corev1.PodTemplateSpec{}
+	for i := 0; i < 2; i++ {
+		s := NewJicofoDeploymentSyncer(jitsi, &jitsi.Shards()[0], c)
+		if _, err := s.Sync(ctx); err != nil {
+			t.Fatalf("reconciliation %d: %v", i, err)
+		}
+		dep := &appsv1.Deployment{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(s.Object().(client.Object)), dep); err != nil {
+			t.Fatal(err)
+		}
+		templates = append(templates, dep.Spec.Template)
+	}
+
+	if !equality.Semantic.DeepEqual(templates[0], templates[1]) {
+		t.Errorf("the template changed on the second reconciliation:\n%s", diff.ObjectReflectDiff(templates[0], templates[1]))
+	}
+	if n := len(templates[1].Spec.ImagePullSecrets); n != 1 {
+		t.Errorf("expected 1 image pull secret, got %d", n)
+	}
+}
+
+// TestPodPatchesRejectAppend checks the JSON patches growing a list at every
+// reconciliation are refused
+func TestPodPatchesRejectAppend(t *testing.T) {
+	for _, patch := range []string{
+		"- op: add\n  path: /spec/imagePullSecrets/-\n  value: {name: registry}\n",
+		"- op: add\n  path: /spec/imagePullSecrets/0\n  value: {name: registry}\n",
+		"- op: remove\n  path: /spec/hostAliases\n",
+	} {
+		overrides := &v1alpha1.PodOverrides{
+			Patches: []v1alpha1.PodPatch{{Type: v1alpha1.PodPatchJSON, Patch: patch}},
+		}
+		template := &corev1.PodTemplateSpec{}
+		if err := applyPodOverrides(overrides, nil, template); err == nil {
+			t.Errorf("patch %q was accepted", patch)
+		}
+	}
+}
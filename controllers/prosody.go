@@ -13,18 +13,18 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func NewProsodyServiceSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+func NewProsodyServiceSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-prosody", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "prosody"),
 			Namespace: jitsi.Namespace,
 		},
 	}
 
 	return syncer.NewObjectSyncer("Service", jitsi, svc, c, func() error {
-		svc.Labels = jitsi.ComponentLabels("prosody")
+		svc.Labels = jitsi.ShardLabels(shard, "prosody")
 		svc.Spec.Type = corev1.ServiceTypeClusterIP
-		svc.Spec.Selector = jitsi.ComponentLabels("prosody")
+		svc.Spec.Selector = jitsi.ShardLabels(shard, "prosody")
 		svc.Spec.Ports = []corev1.ServicePort{
 
 			{
@@ -58,16 +58,17 @@ func NewProsodyServiceSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Inte
 
 }
 
-func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+// NewProsodyDeploymentSyncer runs the Prosody of the shard
+func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-prosody", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "prosody"),
 			Namespace: jitsi.Namespace,
 		},
 	}
 
 	return syncer.NewObjectSyncer("Deployment", jitsi, dep, c, func() error {
-		dep.Labels = jitsi.ComponentLabels("prosody")
+		dep.Labels = jitsi.ShardLabels(shard, "prosody")
 		dep.Spec.Template.Labels = dep.Labels
 		dep.Spec.Selector = &metav1.LabelSelector{
 			MatchLabels: dep.Labels,
@@ -94,13 +95,13 @@ func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.I
 			},
 		}
 
-		container.Env = append(jitsi.EnvVars(ProsodyVariables),
+		container.Env = append(jitsi.ShardEnvVars(shard, &jitsi.Spec.Prosody.ComponentEnv, v1alpha1.ProsodyVariables),
 			corev1.EnvVar{
 				Name: "JICOFO_COMPONENT_SECRET",
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JICOFO_COMPONENT_SECRET",
 					},
@@ -111,7 +112,7 @@ func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.I
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JICOFO_AUTH_PASSWORD",
 					},
@@ -122,7 +123,7 @@ func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.I
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JVB_AUTH_PASSWORD",
 					},
@@ -133,7 +134,7 @@ func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.I
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JIBRI_XMPP_PASSWORD",
 					},
@@ -144,7 +145,7 @@ func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.I
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
 						LocalObjectReference: corev1.LocalObjectReference{
-							Name: jitsi.Name,
+							Name: jitsi.SecretName(),
 						},
 						Key: "JIBRI_RECORDER_PASSWORD",
 					},
@@ -152,7 +153,11 @@ func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.I
 			},
 		)
 
-		if jitsi.Spec.TURN != nil {
+		container.Env = append(container.Env, authEnvVars(jitsi)...)
+
+		if jitsi.ManagedTURN() != nil {
+			container.Env = append(container.Env, turnEnvVars(jitsi)...)
+		} else if jitsi.Spec.TURN != nil {
 			turnPreffix := "TURN"
 			if jitsi.Spec.TURN.TLS {
 				turnPreffix += "S"
@@ -175,6 +180,8 @@ func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.I
 				})
 			}
 		}
+		dep.Spec.Template.Spec.Volumes = nil
+		dep.Spec.Template.Spec.InitContainers = nil
 		if jitsi.Spec.Prosody.CustomProsodyConfig != nil {
 			dep.Spec.Template.Spec.Volumes = append(dep.Spec.Template.Spec.Volumes,
 				corev1.Volume{
@@ -198,7 +205,14 @@ func NewProsodyDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.I
 			})
 		}
 
+		injectProsodyIncludes(jitsi, &dep.Spec.Template, &container)
+		injectICEServers(jitsi, &dep.Spec.Template, &container)
+		injectProsodySnippets(jitsi, &dep.Spec.Template, &container)
+		injectProsodyPlugins(jitsi, &dep.Spec.Template, &container)
+		injectSecretsVersion(jitsi, "prosody", &dep.Spec.Template)
+		injectContentVersion(jitsi, "prosody", false, &dep.Spec.Template)
+
 		dep.Spec.Template.Spec.Containers = []corev1.Container{container}
-		return nil
+		return applyPodOverrides(&jitsi.Spec.Prosody.PodOverrides, jitsi.Spec.Prosody.Env, &dep.Spec.Template)
 	})
 }
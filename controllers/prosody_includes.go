@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// prosodyIncludesAnnotation rolls Prosody out when the files included into
+// its configuration change
+const prosodyIncludesAnnotation = "apps.jit.si/prosody-includes"
+
+// prosodyIncludesDir holds the configuration files mounted by the operator.
+// Prosody includes the files of conf.d in no particular order, these are
+// included one by one after them, in a fixed order.
+const prosodyIncludesDir = "/config/operator.d"
+
+// prosodyIncludesScript runs along the scripts of the image rendering the
+// configuration, after them
+const prosodyIncludesScript = "90-jitsi-operator"
+
+// moderatorsFile makes the moderators admins of Prosody. It is written into
+// the running pods, which reload their configuration rather than restarting.
+const moderatorsFile = "/config/moderators.cfg.lua"
+
+// moderatorsHeader starts the moderators file, alone until there are admins
+// to set
+const moderatorsHeader = "-- moderators, written by the jitsi operator"
+
+// includesMarker starts the includes appended to the configuration
+const includesMarker = "-- jitsi operator includes"
+
+// prosodyIncludes returns the files of prosodyIncludesDir, in the order they
+// are included
+func prosodyIncludes(jitsi *v1alpha1.Jitsi) []string {
+	files := []string{}
+	if len(jitsi.Spec.ICEServers) > 0 {
+		files = append(files, externalServicesFile)
+	}
+	if config := jitsi.Spec.Prosody.Config; config != nil && config.RoomMetadata {
+		files = append(files, roomMetadataFile)
+	}
+	for i := range jitsi.Spec.Prosody.Snippets {
+		files = append(files, snippetFile(&jitsi.Spec.Prosody.Snippets[i]))
+	}
+	return files
+}
+
+// prosodyIncludesConfig renders the script appending the includes to the
+// configuration of Prosody, in place of those of a previous start
+func prosodyIncludesConfig(jitsi *v1alpha1.Jitsi) string {
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	script.WriteString("# generated by the jitsi operator\n")
+	fmt.Fprintf(&script, "[ -f %[1]s ] || echo '%[2]s' > %[1]s\n", moderatorsFile, moderatorsHeader)
+	fmt.Fprintf(&script, "sed -i '/^%s$/,$d' %s\n", includesMarker, prosodyConfig)
+	fmt.Fprintf(&script, "cat >> %s <<'EOF'\n%s\n", prosodyConfig, includesMarker)
+	for _, file := range prosodyIncludes(jitsi) {
+		fmt.Fprintf(&script, "Include %q\n", path.Join(prosodyIncludesDir, file))
+	}
+	fmt.Fprintf(&script, "Include %q\n", moderatorsFile)
+	script.WriteString("EOF\n")
+	return script.String()
+}
+
+// moderatorsConfig makes the moderators admins of Prosody, along with the
+// PROSODY_ADMINS of the instance. The admins of the image, Jicofo and the
+// bridges, are kept as the list replaces them. Without moderators nor
+// PROSODY_ADMINS the file only holds its header and leaves the admins as is.
+func moderatorsConfig(jitsi *v1alpha1.Jitsi, moderators []string) string {
+	admins := []string{}
+	if value := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "PROSODY_ADMINS"); len(value) > 0 {
+		admins = strings.Split(value, ",")
+	}
+	admins = append(admins, moderators...)
+	if len(admins) == 0 {
+		return moderatorsHeader
+	}
+	admins = append(serviceAdmins(jitsi), admins...)
+
+	var cfg strings.Builder
+	cfg.WriteString(moderatorsHeader + "\n")
+	cfg.WriteString("admins = {")
+	for _, admin := range admins {
+		fmt.Fprintf(&cfg, " %q;", strings.TrimSpace(admin))
+	}
+	cfg.WriteString(" }")
+	return cfg.String()
+}
+
+// serviceAdmins returns the accounts of Jicofo and the bridges, which the
+// configuration of the image makes admins of Prosody
+func serviceAdmins(jitsi *v1alpha1.Jitsi) []string {
+	value := func(name, defaultValue string) string {
+		if value := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, name); len(value) > 0 {
+			return value
+		}
+		return defaultValue
+	}
+	domain := value("XMPP_AUTH_DOMAIN", "auth.meet.jitsi")
+	return []string{
+		value("JICOFO_AUTH_USER", "focus") + "@" + domain,
+		value("JVB_AUTH_USER", "jvb") + "@" + domain,
+	}
+}
+
+func prosodyIncludesConfigMapName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-prosody-includes", jitsi.Name)
+}
+
+func NewProsodyIncludesConfigMapSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prosodyIncludesConfigMapName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("ConfigMap", jitsi, cm, c, func() error {
+		cm.Labels = jitsi.ComponentLabels("prosody")
+		cm.Data = map[string]string{
+			prosodyIncludesScript: prosodyIncludesConfig(jitsi),
+		}
+
+		return nil
+	})
+}
+
+// injectProsodyIncludes runs the script including the configuration of the
+// operator when the container starts
+func injectProsodyIncludes(jitsi *v1alpha1.Jitsi, template *corev1.PodTemplateSpec, container *corev1.Container) {
+	mode := int32(0755)
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: "includes",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: prosodyIncludesConfigMapName(jitsi),
+				},
+				DefaultMode: &mode,
+			},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      "includes",
+		MountPath: path.Join("/etc/cont-init.d", prosodyIncludesScript),
+		SubPath:   prosodyIncludesScript,
+	})
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[prosodyIncludesAnnotation] = fmt.Sprintf("%x", sha256.Sum256([]byte(prosodyIncludesConfig(jitsi))))
+}
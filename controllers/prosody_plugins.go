@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// prosodySnippetsAnnotation rolls Prosody out when the snippets generated
+// by the operator change
+const prosodySnippetsAnnotation = "apps.jit.si/prosody-snippets"
+
+// prosodyPluginsDir is on the plugin path of the Prosody image
+const prosodyPluginsDir = "/prosody-plugins-custom"
+
+const roomMetadataFile = "room-metadata.cfg.lua"
+
+// dnsName turns a module name into a valid container or volume name
+func dnsName(name string) string {
+	return strings.ReplaceAll(name, "_", "-")
+}
+
+func snippetFile(snippet *v1alpha1.ProsodySnippet) string {
+	return "snippet-" + snippet.Name + ".cfg.lua"
+}
+
+// breakoutMUC returns the MUC the image configures for the breakout rooms,
+// none when they are disabled
+func breakoutMUC(jitsi *v1alpha1.Jitsi) string {
+	switch jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "ENABLE_BREAKOUT_ROOMS") {
+	case "0", "false":
+		return ""
+	}
+	return "breakout." + jitsi.XMPPDomain()
+}
+
+func roomMetadataConfig(jitsi *v1alpha1.Jitsi) string {
+	domain := jitsi.XMPPDomain()
+	muc := jitsi.ComponentEnvVarValue(&jitsi.Spec.Prosody.ComponentEnv, "XMPP_MUC_DOMAIN")
+	if len(muc) == 0 {
+		muc = "muc.meet.jitsi"
+	}
+
+	var cfg strings.Builder
+	cfg.WriteString("-- generated by the jitsi operator from spec.prosody.config.roomMetadata\n")
+	fmt.Fprintf(&cfg, "\nVirtualHost %q\n    room_metadata_component = %q\n", domain, "metadata."+domain)
+	fmt.Fprintf(&cfg, "\nComponent %q \"room_metadata_component\"\n", "metadata."+domain)
+	fmt.Fprintf(&cfg, "    muc_component = %q\n", muc)
+	if breakout := breakoutMUC(jitsi); len(breakout) > 0 {
+		fmt.Fprintf(&cfg, "    breakout_rooms_component = %q\n", breakout)
+	}
+	return cfg.String()
+}
+
+// prosodySnippets are the configuration files of Prosody generated by the
+// operator, by file name
+func prosodySnippets(jitsi *v1alpha1.Jitsi) map[string]string {
+	snippets := map[string]string{}
+	for i := range jitsi.Spec.Prosody.Snippets {
+		if snippet := &jitsi.Spec.Prosody.Snippets[i]; len(snippet.Config) > 0 {
+			snippets[snippetFile(snippet)] = snippet.Config
+		}
+	}
+	if config := jitsi.Spec.Prosody.Config; config != nil && config.RoomMetadata {
+		snippets[roomMetadataFile] = roomMetadataConfig(jitsi)
+	}
+	return snippets
+}
+
+func prosodySnippetsConfigMapName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-prosody-snippets", jitsi.Name)
+}
+
+func NewProsodySnippetsConfigMapSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prosodySnippetsConfigMapName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("ConfigMap", jitsi, cm, c, func() error {
+		cm.Labels = jitsi.ComponentLabels("prosody")
+		cm.Data = prosodySnippets(jitsi)
+
+		return nil
+	})
+}
+
+// injectProsodySnippets mounts the snippets into prosodyIncludesDir, they are
+// included after the configuration of the image in the order of the spec
+func injectProsodySnippets(jitsi *v1alpha1.Jitsi, template *corev1.PodTemplateSpec, container *corev1.Container) {
+	delete(template.Annotations, prosodySnippetsAnnotation)
+
+	for i := range jitsi.Spec.Prosody.Snippets {
+		snippet := &jitsi.Spec.Prosody.Snippets[i]
+		if snippet.ConfigMap == nil {
+			continue
+		}
+		name := "snippet-" + snippet.Name
+		template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: snippet.ConfigMap.LocalObjectReference,
+					Items: []corev1.KeyToPath{
+						{
+							Key:  snippet.ConfigMap.Key,
+							Path: snippetFile(snippet),
+						},
+					},
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: path.Join(prosodyIncludesDir, snippetFile(snippet)),
+			SubPath:   snippetFile(snippet),
+		})
+	}
+
+	snippets := prosodySnippets(jitsi)
+	if len(snippets) == 0 {
+		return
+	}
+
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: "snippets",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: prosodySnippetsConfigMapName(jitsi),
+				},
+			},
+		},
+	})
+	files := make([]string, 0, len(snippets))
+	for file := range snippets {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	hash := sha256.New()
+	for _, file := range files {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "snippets",
+			MountPath: path.Join(prosodyIncludesDir, file),
+			SubPath:   file,
+		})
+		fmt.Fprintf(hash, "%s\n%s\n", file, snippets[file])
+	}
+
+	// Prosody does not reload its configuration by itself
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[prosodySnippetsAnnotation] = fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// injectProsodyPlugins mounts the modules of the plugins into the custom
+// plugin directory, those of images are copied there by init containers
+func injectProsodyPlugins(jitsi *v1alpha1.Jitsi, template *corev1.PodTemplateSpec, container *corev1.Container) {
+	if len(jitsi.Spec.Prosody.Plugins) == 0 {
+		return
+	}
+
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: "plugins",
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      "plugins",
+		MountPath: prosodyPluginsDir,
+	})
+
+	for _, plugin := range jitsi.Spec.Prosody.Plugins {
+		dir := path.Join(prosodyPluginsDir, "mod_"+plugin.Name)
+		name := "plugin-" + dnsName(plugin.Name)
+
+		if plugin.ConfigMap != nil {
+			template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+				Name: name,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: *plugin.ConfigMap,
+					},
+				},
+			})
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      name,
+				MountPath: dir,
+			})
+			continue
+		}
+
+		source := plugin.Path
+		if len(source) == 0 {
+			source = "/plugins"
+		}
+		template.Spec.InitContainers = append(template.Spec.InitContainers, corev1.Container{
+			Name:    name,
+			Image:   plugin.Image,
+			Command: []string{"cp", "-R", strings.TrimSuffix(source, "/") + "/.", dir},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "plugins",
+					MountPath: prosodyPluginsDir,
+				},
+			},
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+//+kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// prosodyConfig is the configuration prosodyctl runs with in the image
+const prosodyConfig = "/config/prosody.cfg.lua"
+
+// prosodyExecutor runs prosodyctl in the Prosody pods
+type prosodyExecutor struct {
+	config    *rest.Config
+	clientset kubernetes.Interface
+}
+
+func newProsodyExecutor(config *rest.Config) (*prosodyExecutor, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &prosodyExecutor{config: config, clientset: clientset}, nil
+}
+
+// exec runs the shell script in the prosody container of the pod, feeding it
+// stdin so that secrets stay out of the command line recorded by the API
+// server
+func (e *prosodyExecutor) exec(ctx context.Context, pod *corev1.Pod, stdin string, script string, args ...string) error {
+	req := e.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: "prosody",
+			Command:   append([]string{"sh", "-c", script, "prosodyctl"}, args...),
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(e.config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  strings.NewReader(stdin),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		output := strings.TrimSpace(stderr.String() + stdout.String())
+		return fmt.Errorf("prosodyctl failed in %s: %w: %s", pod.Name, err, output)
+	}
+	return nil
+}
+
+// register creates the account of jid, or resets its password
+func (e *prosodyExecutor) register(ctx context.Context, pod *corev1.Pod, jid, password string) error {
+	username, domain, _ := strings.Cut(jid, "@")
+	return e.exec(ctx, pod, password+"\n",
+		`IFS= read -r password && exec prosodyctl --config "$1" register "$2" "$3" "$password"`,
+		prosodyConfig, username, domain)
+}
+
+// unregister deletes the account of jid, if any
+func (e *prosodyExecutor) unregister(ctx context.Context, pod *corev1.Pod, jid string) error {
+	return e.exec(ctx, pod, "",
+		`out=$(prosodyctl --config "$1" deluser "$2" 2>&1) || case "$out" in *"No such user"*) ;; *) echo "$out" >&2; exit 1 ;; esac`,
+		prosodyConfig, jid)
+}
+
+// setAdmins writes the configuration of the admins and reloads Prosody, when
+// it changed
+func (e *prosodyExecutor) setAdmins(ctx context.Context, pod *corev1.Pod, config string) error {
+	return e.exec(ctx, pod, config,
+		`config=$(cat); [ "$config" = "$(cat "$1" 2>/dev/null)" ] && exit 0; printf '%s\n' "$config" > "$1" && exec prosodyctl --config "$2" reload`,
+		moderatorsFile, prosodyConfig)
+}
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// addressesPath is where the bridges find the public addresses of their node,
+// read by the cont-init script of the jvb image
+const addressesPath = "/var/run/jitsi/addresses"
+
+// metadataAddressFile is written by the init container querying the metadata
+// endpoint
+const metadataAddressFile = "public-ip"
+
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+// nodeAddressesChanged filters the node updates that may change the addresses
+// advertised by the bridges, nodes report their status every few minutes
+var nodeAddressesChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, ok := e.ObjectOld.(*corev1.Node)
+		if !ok {
+			return true
+		}
+		newNode, ok := e.ObjectNew.(*corev1.Node)
+		if !ok {
+			return true
+		}
+		return !equality.Semantic.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) ||
+			!equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) ||
+			!equality.Semantic.DeepEqual(oldNode.Annotations, newNode.Annotations)
+	},
+}
+
+func jvbAddressesConfigMapName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-jvb-addresses", jitsi.Name)
+}
+
+func jvbPoolAddressesConfigMapName(pool *v1alpha1.JVBPool) string {
+	return fmt.Sprintf("%s-addresses", pool.Name)
+}
+
+// NewJVBAddressesConfigMapSyncer maps the nodes to the public addresses the
+// bridges of the instance advertise on them
+func NewJVBAddressesConfigMapSyncer(jitsi *v1alpha1.Jitsi, nodes []corev1.Node, c client.Client) syncer.Interface {
+	return newAddressesConfigMapSyncer(jitsi, jvbAddressesConfigMapName(jitsi), jitsi.ComponentLabels("jvb"), jitsi.Spec.JVB.PublicIP, nodes, c)
+}
+
+// NewJVBPoolAddressesConfigMapSyncer maps the nodes to the public addresses
+// the bridges of the pool advertise on them
+func NewJVBPoolAddressesConfigMapSyncer(pool *v1alpha1.JVBPool, jitsi *v1alpha1.Jitsi, nodes []corev1.Node, c client.Client) syncer.Interface {
+	return newAddressesConfigMapSyncer(pool, jvbPoolAddressesConfigMapName(pool), pool.Labels(jitsi), pool.Spec.PublicIP, nodes, c)
+}
+
+func newAddressesConfigMapSyncer(owner client.Object, name string, l labels.Set, publicIP *v1alpha1.PublicIP, nodes []corev1.Node, c client.Client) syncer.Interface {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: owner.GetNamespace(),
+		},
+	}
+
+	return syncer.NewObjectSyncer("ConfigMap", owner, cm, c, func() error {
+		cm.Labels = l
+		cm.Data = map[string]string{}
+		for i := range nodes {
+			if addresses := publicIP.NodeAddresses(&nodes[i]); len(addresses) > 0 {
+				cm.Data[nodes[i].Name] = addresses
+			}
+		}
+
+		return nil
+	})
+}
+
+// listNodes returns the nodes whose addresses publicIP reads, none when the
+// bridges query a metadata endpoint themselves
+func listNodes(ctx context.Context, c client.Client, publicIP *v1alpha1.PublicIP) ([]corev1.Node, error) {
+	if publicIP == nil || !publicIP.NodeBased() {
+		return nil, nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := c.List(ctx, nodes); err != nil {
+		return nil, fmt.Errorf("unable to list the nodes: %w", err)
+	}
+	return nodes.Items, nil
+}
+
+// injectPublicIP makes the bridge of the pod advertise the public address of
+// its node, read from the addresses ConfigMap or from the metadata endpoint
+func injectPublicIP(publicIP *v1alpha1.PublicIP, configMap string, template *corev1.PodTemplateSpec) {
+	if publicIP == nil {
+		return
+	}
+
+	file := mountAddresses(publicIP, configMap, "JVB_NODE_NAME", template)
+	container := &template.Spec.Containers[0]
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  "JVB_ADVERTISE_IPS_FILE",
+		Value: file,
+	})
+
+	if !publicIP.KeepSTUN {
+		setEnvVar(container, "JVB_DISABLE_STUN", "1")
+	}
+}
+
+// mountAddresses mounts the public addresses of the node into the first
+// container of the pod and returns the file holding them, the node name is
+// exposed as nodeNameVar when they come from the addresses ConfigMap
+func mountAddresses(publicIP *v1alpha1.PublicIP, configMap, nodeNameVar string, template *corev1.PodTemplateSpec) string {
+	volume := corev1.Volume{Name: "addresses"}
+	container := &template.Spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      volume.Name,
+		MountPath: addressesPath,
+		ReadOnly:  true,
+	})
+
+	file := path.Join(addressesPath, metadataAddressFile)
+	if publicIP.NodeBased() {
+		// mounted as a directory so that the addresses of new nodes show
+		// up in running pods
+		volume.ConfigMap = &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+		}
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: nodeNameVar,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "spec.nodeName",
+				},
+			},
+		})
+		file = path.Join(addressesPath, fmt.Sprintf("$(%s)", nodeNameVar))
+	} else {
+		volume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+		template.Spec.InitContainers = []corev1.Container{metadataContainer(publicIP)}
+	}
+	template.Spec.Volumes = append(template.Spec.Volumes, volume)
+
+	return file
+}
+
+// metadataContainer writes the address returned by the metadata endpoint
+// where the bridge reads it
+func metadataContainer(publicIP *v1alpha1.PublicIP) corev1.Container {
+	command := []string{"wget", "-q", "-T", "5", "-O", path.Join(addressesPath, metadataAddressFile)}
+
+	headers := make([]string, 0, len(publicIP.MetadataHeaders))
+	for name := range publicIP.MetadataHeaders {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		command = append(command, "--header", fmt.Sprintf("%s: %s", name, publicIP.MetadataHeaders[name]))
+	}
+
+	return corev1.Container{
+		Name:    "public-ip",
+		Image:   publicIP.MetadataImage,
+		Command: append(command, publicIP.MetadataURL),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "addresses",
+				MountPath: addressesPath,
+			},
+		},
+	}
+}
+
+// jitsisOfNode enqueues the instances advertising the addresses of the nodes
+func (r *JitsiReconciler) jitsisOfNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	jitsis := &v1alpha1.JitsiList{}
+	if err := r.Client.List(ctx, jitsis); err != nil {
+		r.Log.Error(err, "unable to list the jitsis of node", "node", obj.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range jitsis.Items {
+		jitsi := &jitsis.Items[i]
+		if publicIP := jitsi.Spec.JVB.PublicIP; publicIP != nil && publicIP.NodeBased() ||
+			turnNodeBased(jitsi) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(jitsi),
+			})
+		}
+	}
+	return requests
+}
+
+// poolsOfNode enqueues all the pools, their discovery settings may be
+// inherited from their instance
+func (r *JVBPoolReconciler) poolsOfNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	pools := &v1alpha1.JVBPoolList{}
+	if err := r.Client.List(ctx, pools); err != nil {
+		r.Log.Error(err, "unable to list the pools of node", "node", obj.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range pools.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&pools.Items[i]),
+		})
+	}
+	return requests
+}
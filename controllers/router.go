@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const routerPort = 8080
+
+// routerPeersPort is where the routers share their stick table
+const routerPeersPort = 10000
+
+// routerConfigDir holds the configuration of HAProxy in the image
+const routerConfigDir = "/usr/local/etc/haproxy"
+
+// routerPIDFile is written by the HAProxy master, which reloads its
+// configuration on SIGUSR2
+const routerPIDFile = "/var/run/haproxy/haproxy.pid"
+
+// routerReloadScript signals HAProxy once the ConfigMap mounted into the pod
+// changed, so that the routers follow the shards without restarting
+const routerReloadScript = `last=$$(cksum < "$1")
+while sleep 5; do
+  sum=$$(cksum < "$1")
+  if [ "$sum" != "$last" ] && kill -USR2 "$$(cat "$2")"; then last=$sum; fi
+done`
+
+func routerName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-router", jitsi.Name)
+}
+
+func routerPeersName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-router-peers", jitsi.Name)
+}
+
+// routerConfig renders the HAProxy configuration routing each room to a
+// shard. Clients pass the room in the room parameter of the BOSH and
+// websocket URLs, it is hashed consistently so that adding or removing a
+// shard only moves the rooms of that shard, and a stick table keeps running
+// conferences on the shard they started on. The routers share the table
+// through their peers section, each router being known by the name of its
+// pod, and hand it over to the new process when they reload.
+func routerConfig(jitsi *v1alpha1.Jitsi) string {
+	var cfg strings.Builder
+
+	cfg.WriteString(`global
+    log stdout format raw local0 info
+
+defaults
+    log global
+    mode http
+    option httplog
+    option forwardfor
+    timeout connect 5s
+    timeout client 1h
+    timeout server 1h
+    timeout tunnel 1h
+
+`)
+	if replicas := jitsi.Spec.Shards.Router.Replicas; replicas != nil && *replicas > 0 {
+		cfg.WriteString("peers routers\n")
+		for i := int32(0); i < *replicas; i++ {
+			peer := fmt.Sprintf("%s-%d", routerName(jitsi), i)
+			fmt.Fprintf(&cfg, "    peer %s %s.%s.%s.svc.cluster.local:%d\n", peer, peer, routerPeersName(jitsi), jitsi.Namespace, routerPeersPort)
+		}
+		cfg.WriteString("\n")
+	}
+	cfg.WriteString("frontend http\n")
+	fmt.Fprintf(&cfg, "    bind :%d\n", routerPort)
+	cfg.WriteString(`    monitor-uri /healthz
+    default_backend shards
+
+backend shards
+    balance url_param room
+    hash-type consistent
+`)
+	cfg.WriteString("    stick-table type string len 256 size 100k expire 1h")
+	if replicas := jitsi.Spec.Shards.Router.Replicas; replicas != nil && *replicas > 0 {
+		cfg.WriteString(" peers routers")
+	}
+	cfg.WriteString("\n    stick on url_param(room)\n")
+
+	shards := jitsi.Shards()
+	for i := range shards {
+		fmt.Fprintf(&cfg, "    server %s %s.%s.svc.cluster.local:80 check init-addr last,libc,none\n",
+			shards[i].Name, jitsi.ShardName(&shards[i], "web"), jitsi.Namespace)
+	}
+
+	return cfg.String()
+}
+
+func NewRouterConfigMapSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      routerName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("ConfigMap", jitsi, cm, c, func() error {
+		cm.Labels = jitsi.ComponentLabels("router")
+		cm.Data = map[string]string{
+			"haproxy.cfg": routerConfig(jitsi),
+		}
+
+		return nil
+	})
+}
+
+// NewRouterStatefulSetSyncer runs the routers under stable names, which
+// their peers section refers to
+func NewRouterStatefulSetSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      routerName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("StatefulSet", jitsi, sts, c, func() error {
+		router := jitsi.Spec.Shards.Router
+
+		sts.Labels = jitsi.ComponentLabels("router")
+		sts.Spec.Template.Labels = sts.Labels
+		sts.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: sts.Labels,
+		}
+		sts.Spec.ServiceName = routerPeersName(jitsi)
+		sts.Spec.PodManagementPolicy = appsv1.ParallelPodManagement
+		sts.Spec.Replicas = router.Replicas
+		sts.Spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
+		sts.Spec.Template.Spec.Affinity = &router.Affinity
+
+		// the reloader signals HAProxy
+		shareProcessNamespace := true
+		sts.Spec.Template.Spec.ShareProcessNamespace = &shareProcessNamespace
+
+		sts.Spec.Template.Spec.Volumes = []corev1.Volume{
+			{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: routerName(jitsi),
+						},
+					},
+				},
+			},
+			{
+				Name: "run",
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{},
+				},
+			},
+		}
+		mounts := []corev1.VolumeMount{
+			{
+				Name:      "config",
+				MountPath: routerConfigDir,
+				ReadOnly:  true,
+			},
+			{
+				Name:      "run",
+				MountPath: path.Dir(routerPIDFile),
+			},
+		}
+
+		container := corev1.Container{
+			Name:            "haproxy",
+			Image:           router.Image,
+			ImagePullPolicy: router.ImagePullPolicy,
+			Args:            []string{"-p", routerPIDFile, "-f", path.Join(routerConfigDir, "haproxy.cfg")},
+			Ports: []corev1.ContainerPort{
+				{
+					Name:          "http",
+					ContainerPort: routerPort,
+				},
+				{
+					Name:          "peers",
+					ContainerPort: routerPeersPort,
+				},
+			},
+			VolumeMounts: mounts,
+			ReadinessProbe: &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					HTTPGet: &corev1.HTTPGetAction{
+						Path: "/healthz",
+						Port: intstr.FromInt(routerPort),
+					},
+				},
+			},
+		}
+
+		if router.Resources != nil {
+			container.Resources = *router.Resources
+		}
+
+		reloader := corev1.Container{
+			Name:            "reloader",
+			Image:           router.Image,
+			ImagePullPolicy: router.ImagePullPolicy,
+			Command:         []string{"sh", "-c", routerReloadScript, "reloader", path.Join(routerConfigDir, "haproxy.cfg"), routerPIDFile},
+			VolumeMounts:    mounts,
+		}
+
+		sts.Spec.Template.Spec.Containers = []corev1.Container{container, reloader}
+
+		return nil
+	})
+}
+
+// NewRouterPeersServiceSyncer gives each router a DNS name its peers reach
+// it on, as soon as its pod is created
+func NewRouterPeersServiceSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      routerPeersName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("Service", jitsi, svc, c, func() error {
+		svc.Labels = jitsi.ComponentLabels("router")
+		svc.Spec.ClusterIP = corev1.ClusterIPNone
+		svc.Spec.PublishNotReadyAddresses = true
+		svc.Spec.Selector = jitsi.ComponentLabels("router")
+		svc.Spec.Ports = []corev1.ServicePort{
+			{
+				Name:       "peers",
+				Port:       routerPeersPort,
+				TargetPort: intstr.FromInt(routerPeersPort),
+				Protocol:   corev1.ProtocolTCP,
+			},
+		}
+
+		return nil
+	})
+}
+
+// NewRouterServiceSyncer takes over the web service of a sharded instance so
+// that the ingress sends the users to the router
+func NewRouterServiceSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-web", jitsi.Name),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("Service", jitsi, svc, c, func() error {
+		svc.Labels = jitsi.ComponentLabels("router")
+		svc.Spec.Type = corev1.ServiceTypeClusterIP
+		svc.Spec.Selector = jitsi.ComponentLabels("router")
+		svc.Spec.Ports = []corev1.ServicePort{
+			{
+				Name:       "http",
+				Port:       80,
+				TargetPort: intstr.FromInt(routerPort),
+				Protocol:   corev1.ProtocolTCP,
+			},
+		}
+
+		return nil
+	})
+}
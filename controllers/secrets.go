@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+// secretsVersionAnnotation restarts the components when their passwords
+// change, so that none of them is left with a stale one
+const secretsVersionAnnotation = "apps.jit.si/secrets-version"
+
+// secretsRolloutInterval is how often a rollout of new passwords checks the
+// components of its stage are rolled out
+const secretsRolloutInterval = 10 * time.Second
+
+const (
+	reasonSecretsRotated          = "SecretsRotated"
+	reasonSecretsRolledOut        = "SecretsRolledOut"
+	reasonNoSecretsRolloutPending = "NoSecretsRolloutPending"
+)
+
+// secretsRolloutStages roll out one after the other when the passwords
+// change. Prosody registers the accounts the others log in with, the bridges
+// come last through their graceful shutdown.
+var secretsRolloutStages = [][]string{{"prosody", "turn"}, {"jicofo", "jibri"}, {"jvb"}}
+
+// secretVars returns the keys of the Secret of the components
+func secretVars(jitsi *v1alpha1.Jitsi) []string {
+	vars := secretsVar
+	if jitsi.ManagedTURN() != nil {
+		vars = append(vars[:len(vars):len(vars)], turnSecretVar)
+	}
+	return vars
+}
+
+// syncSecrets generates the passwords of the components, rotating them when
+// due, or checks the existing Secret holds them. Their version is recorded in
+// the status for the components to roll out with, see rollOutSecrets. A due
+// rotation waits like the rollout, it returns how long.
+func (r *JitsiReconciler) syncSecrets(ctx context.Context, jitsi *v1alpha1.Jitsi) (time.Duration, error) {
+	if jitsi.Status.Secrets == nil {
+		jitsi.Status.Secrets = &v1alpha1.SecretsStatus{}
+	}
+	previous := jitsi.Status.Secrets.Version
+
+	wait := time.Duration(0)
+	if !jitsi.ExternalSecrets() {
+		reason := jitsi.RotationDue(time.Now())
+		if len(reason) > 0 && len(previous) > 0 {
+			if secretsRollingOut(jitsi.Status.Secrets) {
+				// rotated once the last passwords are rolled out
+				reason = ""
+			} else {
+				var err error
+				if wait, err = r.gateSecrets(ctx, jitsi); err != nil {
+					return 0, err
+				}
+				if wait > 0 {
+					reason = ""
+				}
+			}
+		}
+		if err := syncer.Sync(ctx, NewJitsiSecretSyncer(jitsi, len(reason) > 0, r.Client, r.Recorder), r.Recorder); err != nil {
+			return 0, err
+		}
+		if len(reason) > 0 {
+			now := metav1.Now()
+			jitsi.Status.Secrets.LastRotationTime = &now
+			jitsi.Status.Secrets.RotationRequest = jitsi.Annotations[v1alpha1.RotateSecretsAnnotation]
+			r.event(jitsi, corev1.EventTypeNormal, reasonSecretsRotated, "passwords rotated, "+reason)
+			// a rotation is not to be repeated by a failing reconciliation
+			if err := r.updateStatus(ctx, jitsi); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: jitsi.Namespace, Name: jitsi.SecretName()}, secret); err != nil {
+		return 0, fmt.Errorf("unable to get secret %s: %w", jitsi.SecretName(), err)
+	}
+
+	hash := sha256.New()
+	for _, key := range secretVars(jitsi) {
+		if len(secret.Data[key]) == 0 {
+			return 0, fmt.Errorf("secret %s has no %s", secret.Name, key)
+		}
+		fmt.Fprintf(hash, "%s=%s\n", key, secret.Data[key])
+	}
+	jitsi.Status.Secrets.Version = fmt.Sprintf("%x", hash.Sum(nil))[:16]
+
+	// the components of a new instance, or of one deployed before the
+	// versions were tracked, start with the passwords they already have
+	if jitsi.Status.Secrets.Components == nil {
+		if len(previous) == 0 {
+			previous = jitsi.Status.Secrets.Version
+		}
+		jitsi.Status.Secrets.Components = map[string]string{}
+		for _, stage := range secretsRolloutStages {
+			for _, component := range stage {
+				jitsi.Status.Secrets.Components[component] = previous
+			}
+		}
+	}
+
+	return wait, nil
+}
+
+// rollOutSecrets rolls new passwords out to the components one stage after
+// the other, once the previous stage is rolled out. A rollout restarts
+// Prosody, it waits for a maintenance window and for the conferences to end
+// like an upgrade. It returns how long to wait before going on.
+func (r *JitsiReconciler) rollOutSecrets(ctx context.Context, jitsi *v1alpha1.Jitsi) (time.Duration, error) {
+	status := jitsi.Status.Secrets
+	started := false
+	for _, stage := range secretsRolloutStages {
+		for _, component := range stage {
+			started = started || status.Components[component] == status.Version
+		}
+	}
+
+	for _, stage := range secretsRolloutStages {
+		behind := []string{}
+		for _, component := range stage {
+			if status.Components[component] != status.Version {
+				behind = append(behind, component)
+			}
+		}
+
+		if len(behind) == 0 {
+			done, err := r.rolledOut(ctx, jitsi, stage)
+			if err != nil {
+				return 0, err
+			}
+			if !done {
+				return secretsRolloutInterval, nil
+			}
+			continue
+		}
+
+		if !started {
+			wait, err := r.gateSecrets(ctx, jitsi)
+			if err != nil || wait > 0 {
+				return wait, err
+			}
+		}
+		for _, component := range behind {
+			status.Components[component] = status.Version
+		}
+		r.event(jitsi, corev1.EventTypeNormal, reasonSecretsRolledOut,
+			fmt.Sprintf("rolling the passwords out to %s", strings.Join(behind, ", ")))
+		return secretsRolloutInterval, nil
+	}
+
+	// a blocked rotation is still pending
+	if len(jitsi.RotationDue(time.Now())) == 0 {
+		status.PendingSince = nil
+		jitsi.SetCondition(v1alpha1.ConditionSecretsRolloutBlocked, metav1.ConditionFalse, reasonNoSecretsRolloutPending, "the components run with the current passwords")
+	}
+	return 0, nil
+}
+
+// secretsRollingOut tells whether some components do not run with the
+// current passwords yet
+func secretsRollingOut(status *v1alpha1.SecretsStatus) bool {
+	for _, version := range status.Components {
+		if version != status.Version {
+			return true
+		}
+	}
+	return false
+}
+
+// gateSecrets returns how long new passwords wait for a maintenance window
+// or for the conferences to end, under the policy of the upgrades and as long
+// as they are unknown, 0 when they may roll out
+func (r *JitsiReconciler) gateSecrets(ctx context.Context, jitsi *v1alpha1.Jitsi) (time.Duration, error) {
+	now := time.Now().In(jitsi.Location())
+	status := jitsi.Status.Secrets
+	if status.PendingSince == nil {
+		pendingSince := metav1.NewTime(now)
+		status.PendingSince = &pendingSince
+	}
+
+	policy := v1alpha1.UpgradeStrategy{}
+	if jitsi.Spec.Upgrade != nil {
+		policy = *jitsi.Spec.Upgrade
+	}
+
+	wait, next, err := maintenanceWait(&policy, now)
+	if err != nil {
+		return 0, err
+	}
+	if wait > 0 {
+		r.blockSecrets(jitsi, reasonOutsideMaintenanceWindow,
+			fmt.Sprintf("new passwords wait for the maintenance window opening at %s", next.Format(time.RFC3339)))
+		return wait, nil
+	}
+
+	conferences, known := r.activeConferences(ctx, jitsi)
+	if !known {
+		unknownFor := statsUnknownFor(jitsi, status.PendingSince.Time, now)
+		if unknownFor < statsTimeout {
+			r.blockSecrets(jitsi, reasonStatsUnavailable, "new passwords wait for Jicofo to report its conferences")
+			return statsRetryInterval, nil
+		}
+		r.event(jitsi, corev1.EventTypeWarning, "SecretsRolloutForced",
+			fmt.Sprintf("no conference stats for %s, rolling the new passwords out anyway", unknownFor.Round(time.Second)))
+		return 0, nil
+	}
+	if conferences > 0 {
+		pendingFor := now.Sub(status.PendingSince.Time)
+		if policy.MaxWait != nil && pendingFor >= policy.MaxWait.Duration {
+			r.event(jitsi, corev1.EventTypeWarning, "SecretsRolloutForced",
+				fmt.Sprintf("new passwords pending for %s, forcing them with %d conferences", pendingFor.Round(time.Second), conferences))
+			return 0, nil
+		}
+		r.blockSecrets(jitsi, reasonActiveConferences,
+			fmt.Sprintf("new passwords wait for %d conferences to end", conferences))
+		return upgradeRetryInterval, nil
+	}
+
+	return 0, nil
+}
+
+func (r *JitsiReconciler) blockSecrets(jitsi *v1alpha1.Jitsi, reason, message string) {
+	condition := meta.FindStatusCondition(jitsi.Status.Conditions, v1alpha1.ConditionSecretsRolloutBlocked)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reason {
+		r.event(jitsi, corev1.EventTypeNormal, "SecretsRolloutBlocked", message)
+	}
+	jitsi.SetCondition(v1alpha1.ConditionSecretsRolloutBlocked, metav1.ConditionTrue, reason, message)
+}
+
+// rolledOut tells whether the Deployments and DaemonSets of the components
+// run the passwords recorded for them, with all their pods available. The
+// bridges of the pools of the instance are part of the jvb component.
+func (r *JitsiReconciler) rolledOut(ctx context.Context, jitsi *v1alpha1.Jitsi, components []string) (bool, error) {
+	for _, component := range components {
+		version := jitsi.Status.Secrets.Components[component]
+		done, err := r.workloadsRolledOut(ctx, jitsi.Namespace, jitsi.ComponentLabels(component), []metav1.Object{jitsi}, version)
+		if err != nil || !done {
+			return false, err
+		}
+		if component != "jvb" {
+			continue
+		}
+
+		pools, err := r.poolsOf(ctx, jitsi)
+		if err != nil {
+			return false, err
+		}
+		owners := []metav1.Object{}
+		for i := range pools {
+			owners = append(owners, &pools[i])
+		}
+		if len(owners) == 0 {
+			continue
+		}
+		done, err = r.workloadsRolledOut(ctx, jitsi.Namespace, jitsi.ComponentLabels("jvb-pool"), owners, version)
+		if err != nil || !done {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// workloadsRolledOut tells whether the Deployments and DaemonSets matching
+// the labels and controlled by one of the owners run the version of the
+// passwords, with all their pods available
+func (r *JitsiReconciler) workloadsRolledOut(ctx context.Context, namespace string, labels map[string]string, owners []metav1.Object, version string) (bool, error) {
+	selector := client.MatchingLabels(labels)
+
+	deployments := &appsv1.DeploymentList{}
+	if err := r.Client.List(ctx, deployments, client.InNamespace(namespace), selector); err != nil {
+		return false, err
+	}
+	for i := range deployments.Items {
+		dep := &deployments.Items[i]
+		if !controlledByAny(dep, owners) {
+			continue
+		}
+		replicas := int32(1)
+		if dep.Spec.Replicas != nil {
+			replicas = *dep.Spec.Replicas
+		}
+		if dep.Spec.Template.Annotations[secretsVersionAnnotation] != version ||
+			dep.Status.ObservedGeneration < dep.Generation ||
+			dep.Status.UpdatedReplicas < replicas ||
+			dep.Status.Replicas > dep.Status.UpdatedReplicas ||
+			dep.Status.AvailableReplicas < dep.Status.UpdatedReplicas {
+			return false, nil
+		}
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := r.Client.List(ctx, daemonSets, client.InNamespace(namespace), selector); err != nil {
+		return false, err
+	}
+	for i := range daemonSets.Items {
+		ds := &daemonSets.Items[i]
+		if !controlledByAny(ds, owners) {
+			continue
+		}
+		if ds.Spec.Template.Annotations[secretsVersionAnnotation] != version ||
+			ds.Status.ObservedGeneration < ds.Generation ||
+			ds.Status.UpdatedNumberScheduled < ds.Status.DesiredNumberScheduled ||
+			ds.Status.NumberAvailable < ds.Status.DesiredNumberScheduled {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// injectSecretsVersion rolls the pods of the component out when the
+// passwords recorded for it change. Templates are left as they are until a
+// version is recorded.
+func injectSecretsVersion(jitsi *v1alpha1.Jitsi, component string, template *corev1.PodTemplateSpec) {
+	if jitsi.Status.Secrets == nil || len(jitsi.Status.Secrets.Components[component]) == 0 {
+		return
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[secretsVersionAnnotation] = jitsi.Status.Secrets.Components[component]
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	reasonSuspended          = "Suspended"
+	reasonReconciling        = "Reconciling"
+	reasonActiveConferences  = "ActiveConferences"
+	reasonNoUpgradePending   = "NoUpgradePending"
+	reasonSyncFailed         = "SyncFailed"
+	reasonInvalidSpec        = "InvalidSpec"
+	reasonSyncSucceeded      = "SyncSucceeded"
+	reasonComponentsReady    = "ComponentsReady"
+	reasonComponentsNotReady = "ComponentsNotReady"
+)
+
+// componentStatus sums the replica counts of the Deployments and DaemonSets
+// controlled by jitsi for the given component. The pods of a workload whose
+// latest spec the controller has not observed yet are not counted as updated.
+func (r *JitsiReconciler) componentStatus(ctx context.Context, jitsi *v1alpha1.Jitsi, component string) (*v1alpha1.ComponentStatus, error) {
+	status := &v1alpha1.ComponentStatus{}
+	selector := client.MatchingLabels(jitsi.ComponentLabels(component))
+
+	deployments := appsv1.DeploymentList{}
+	if err := r.Client.List(ctx, &deployments, client.InNamespace(jitsi.Namespace), selector); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		dep := &deployments.Items[i]
+		if !metav1.IsControlledBy(dep, jitsi) {
+			continue
+		}
+		if dep.Spec.Replicas != nil {
+			status.Replicas += *dep.Spec.Replicas
+		}
+		status.ReadyReplicas += dep.Status.ReadyReplicas
+		if dep.Status.ObservedGeneration >= dep.Generation && dep.Status.Replicas <= dep.Status.UpdatedReplicas {
+			status.UpdatedReplicas += dep.Status.UpdatedReplicas
+		}
+	}
+
+	daemonSets := appsv1.DaemonSetList{}
+	if err := r.Client.List(ctx, &daemonSets, client.InNamespace(jitsi.Namespace), selector); err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		ds := &daemonSets.Items[i]
+		if !metav1.IsControlledBy(ds, jitsi) {
+			continue
+		}
+		status.Replicas += ds.Status.DesiredNumberScheduled
+		status.ReadyReplicas += ds.Status.NumberReady
+		if ds.Status.ObservedGeneration >= ds.Generation {
+			status.UpdatedReplicas += ds.Status.UpdatedNumberScheduled
+		}
+	}
+
+	return status, nil
+}
+
+// updateComponentsStatus refreshes the per-component replica counts and the
+// Ready and Progressing conditions derived from them, a component is still
+// progressing while it rolls out
+func (r *JitsiReconciler) updateComponentsStatus(ctx context.Context, jitsi *v1alpha1.Jitsi) error {
+	components := []struct {
+		name   string
+		status **v1alpha1.ComponentStatus
+	}{
+		{"prosody", &jitsi.Status.Prosody},
+		{"jicofo", &jitsi.Status.Jicofo},
+		{"jvb", &jitsi.Status.JVB},
+		{"web", &jitsi.Status.Web},
+		{"jibri", &jitsi.Status.Jibri},
+	}
+
+	notReady := []string{}
+	for _, component := range components {
+		if component.name == "jibri" && !jitsi.Spec.Jibri.Enabled {
+			*component.status = nil
+			continue
+		}
+
+		status, err := r.componentStatus(ctx, jitsi, component.name)
+		if err != nil {
+			return err
+		}
+		*component.status = status
+
+		if !status.Ready() {
+			notReady = append(notReady, fmt.Sprintf("%s (%d/%d ready, %d/%d updated)", component.name, status.ReadyReplicas, status.Replicas, status.UpdatedReplicas, status.Replicas))
+		}
+	}
+
+	if len(notReady) == 0 {
+		jitsi.SetCondition(v1alpha1.ConditionReady, metav1.ConditionTrue, reasonComponentsReady, "all components are ready")
+		jitsi.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionFalse, reasonComponentsReady, "all components are ready")
+	} else {
+		message := "waiting for " + strings.Join(notReady, ", ")
+		jitsi.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonComponentsNotReady, message)
+		jitsi.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue, reasonComponentsNotReady, message)
+	}
+
+	return nil
+}
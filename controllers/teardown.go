@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+	"github.com/presslabs/controller-util/pkg/syncer"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// teardownFinalizer holds a deleted Jitsi until its components are taken
+// down in order
+const teardownFinalizer = "apps.jit.si/teardown"
+
+// teardownPollInterval is how often the progress of a teardown is checked
+const teardownPollInterval = 10 * time.Second
+
+// defaultTeardownTimeout is how long a teardown waits for conferences to end
+// when spec.teardown.timeout is unset
+const defaultTeardownTimeout = time.Hour
+
+const (
+	reasonWaitingForConferences = "WaitingForConferences"
+	reasonDeletingComponents    = "DeletingComponents"
+)
+
+type teardownStep struct {
+	component string
+	syncers   []syncer.Interface
+}
+
+// teardownSteps lists the components in the order they are deleted: users are
+// cut off first, then recorders and bridges, those of the pools included, and
+// the signaling last so the bridges can gracefully leave their conferences
+func teardownSteps(jitsi *v1alpha1.Jitsi, pools []v1alpha1.JVBPool, c client.Client) []teardownStep {
+	web := teardownStep{"web", []syncer.Interface{
+		NewIngressSyncer(jitsi, c),
+		NewRouterServiceSyncer(jitsi, c),
+		NewRouterStatefulSetSyncer(jitsi, c),
+		NewRouterPeersServiceSyncer(jitsi, c),
+		NewRouterConfigMapSyncer(jitsi, c),
+	}}
+	jibri := teardownStep{"jibri", []syncer.Interface{}}
+	jvb := teardownStep{"jvb", []syncer.Interface{
+		NewJVBPodMonitorSyncer(jitsi, c),
+		NewUpstreamSecretSyncer(jitsi, nil, c),
+		NewJVBAddressesConfigMapSyncer(jitsi, nil, c),
+		newBridgesConfigMapSyncer(jitsi, jvbBridgesConfigMapName(jitsi), nil, nil, c),
+		NewTURNServiceSyncer(jitsi, c),
+		NewTURNDeploymentSyncer(jitsi, "", c),
+		NewTURNDaemonSetSyncer(jitsi, c),
+		NewTURNAddressesConfigMapSyncer(jitsi, nil, c),
+		NewTURNCertificateSyncer(jitsi, c),
+	}}
+	for i := range pools {
+		pool := &pools[i]
+		jvb.syncers = append(jvb.syncers,
+			NewJVBPoolHPASyncer(pool, jitsi, c),
+			NewJVBPoolScaledObjectSyncer(pool, jitsi, c),
+			NewJVBPoolDeploymentSyncer(pool, jitsi, nil, c),
+			NewJVBPoolDaemonSetSyncer(pool, jitsi, nil, c),
+		)
+	}
+	jicofo := teardownStep{"jicofo", []syncer.Interface{
+		NewJicofoServiceMonitorSyncer(jitsi, c),
+	}}
+	prosody := teardownStep{"prosody", []syncer.Interface{
+		NewJitsiSecretSyncer(jitsi, false, c, nil),
+		NewProsodyIncludesConfigMapSyncer(jitsi, c),
+		NewProsodyICEConfigMapSyncer(jitsi, c),
+		NewProsodySnippetsConfigMapSyncer(jitsi, c),
+	}}
+
+	shards := jitsi.Shards()
+	for i := range shards {
+		shard := &shards[i]
+		web.syncers = append(web.syncers,
+			NewWebServiceSyncer(jitsi, shard, c),
+			NewWebDeploymentSyncer(jitsi, shard, c),
+		)
+		jibri.syncers = append(jibri.syncers, NewJibriDeploymentSyncer(jitsi, shard, c))
+		jvb.syncers = append(jvb.syncers,
+			NewJVBHPASyncer(jitsi, shard, c),
+			NewJVBScaledObjectSyncer(jitsi, shard, c),
+			NewJVBDeploymentSyncer(jitsi, shard, c),
+			NewJVBDaemonSetSyncer(jitsi, shard, c),
+		)
+		jicofo.syncers = append(jicofo.syncers, NewJicofoDeploymentSyncer(jitsi, shard, c))
+		prosody.syncers = append(prosody.syncers,
+			NewProsodyFederationServiceSyncer(jitsi, shard, c),
+			NewProsodyServiceSyncer(jitsi, shard, c),
+			NewProsodyDeploymentSyncer(jitsi, shard, c),
+		)
+	}
+
+	return []teardownStep{web, jibri, jvb, jicofo, prosody}
+}
+
+// teardown takes a deleted instance down component by component and
+// releases the finalizer once everything is gone
+func (r *JitsiReconciler) teardown(ctx context.Context, jitsi *v1alpha1.Jitsi) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(jitsi, teardownFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	// once components are being deleted the stats are no longer reliable
+	terminating := meta.FindStatusCondition(jitsi.Status.Conditions, v1alpha1.ConditionTerminating)
+	if terminating == nil || terminating.Reason != reasonDeletingComponents {
+		if message := r.awaitConferences(ctx, jitsi); len(message) > 0 {
+			jitsi.SetCondition(v1alpha1.ConditionTerminating, metav1.ConditionTrue, reasonWaitingForConferences, message)
+			if err := r.updateStatus(ctx, jitsi); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: teardownPollInterval}, nil
+		}
+	}
+
+	pools, err := r.poolsOf(ctx, jitsi)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	owners := []metav1.Object{jitsi}
+	for i := range pools {
+		owners = append(owners, &pools[i])
+	}
+
+	for _, step := range teardownSteps(jitsi, pools, r.Client) {
+		remaining, err := r.deleteObjects(ctx, owners, step.syncers)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if remaining > 0 {
+			r.Log.Info(fmt.Sprintf("waiting for %d %s objects to be deleted", remaining, step.component))
+			jitsi.SetCondition(v1alpha1.ConditionTerminating, metav1.ConditionTrue, reasonDeletingComponents,
+				fmt.Sprintf("waiting for %s to be deleted", step.component))
+			if err := r.updateStatus(ctx, jitsi); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: teardownPollInterval}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(jitsi, teardownFinalizer)
+	return ctrl.Result{}, r.Client.Update(ctx, jitsi)
+}
+
+// awaitConferences returns why the teardown should keep waiting, or an empty
+// string once no conference nor recording is running or the timeout expired
+func (r *JitsiReconciler) awaitConferences(ctx context.Context, jitsi *v1alpha1.Jitsi) string {
+	teardown := jitsi.Spec.Teardown
+	if teardown == nil || !teardown.WaitForConferences {
+		return ""
+	}
+
+	timeout := defaultTeardownTimeout
+	if teardown.Timeout != nil {
+		timeout = teardown.Timeout.Duration
+	}
+	if waited := time.Since(jitsi.DeletionTimestamp.Time); waited >= timeout {
+		r.Log.Info(fmt.Sprintf("waited %s for conferences to end, tearing down anyway", waited.Round(time.Second)))
+		return ""
+	}
+
+	r.refreshStats(ctx, jitsi)
+	stats := jitsi.Status.Stats
+	if stats == nil || stats.Stale {
+		return "waiting for Jicofo to report the running conferences"
+	}
+
+	recordings := stats.JibriInstances - stats.JibriAvailable
+	if stats.Conferences > 0 || recordings > 0 {
+		return fmt.Sprintf("waiting for %d conferences and %d recordings to end", stats.Conferences, recordings)
+	}
+
+	return ""
+}
+
+// poolsOf returns the pools running bridges for jitsi
+func (r *JitsiReconciler) poolsOf(ctx context.Context, jitsi *v1alpha1.Jitsi) ([]v1alpha1.JVBPool, error) {
+	list := &v1alpha1.JVBPoolList{}
+	if err := r.Client.List(ctx, list, client.InNamespace(jitsi.Namespace)); err != nil {
+		return nil, err
+	}
+
+	pools := []v1alpha1.JVBPool{}
+	for _, pool := range list.Items {
+		if pool.Spec.JitsiRef.Name == jitsi.Name {
+			pools = append(pools, pool)
+		}
+	}
+	return pools, nil
+}
+
+// deleteObjects deletes the objects of the syncers that are controlled by one
+// of the owners and returns how many of them still exist. Deletion is done in
+// the foreground so that an object is only gone once its pods are.
+func (r *JitsiReconciler) deleteObjects(ctx context.Context, owners []metav1.Object, syncers []syncer.Interface) (int, error) {
+	remaining := 0
+	for _, s := range syncers {
+		obj := s.Object().(client.Object)
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			// objects of kinds unknown to the cluster, like monitors without
+			// the prometheus operator, cannot exist
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			if err := ignoreNotFound(err); err != nil {
+				return remaining, err
+			}
+			continue
+		}
+
+		if !controlledByAny(obj, owners) {
+			continue
+		}
+
+		remaining++
+		if obj.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if err := r.Client.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil {
+			if err := ignoreNotFound(err); err != nil {
+				return remaining, err
+			}
+		}
+	}
+
+	return remaining, nil
+}
+
+func controlledByAny(obj metav1.Object, owners []metav1.Object) bool {
+	for _, owner := range owners {
+		if metav1.IsControlledBy(obj, owner) {
+			return true
+		}
+	}
+	return false
+}
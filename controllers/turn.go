@@ -0,0 +1,414 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/presslabs/controller-util/pkg/syncer"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+)
+
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// turnCertificatePath is where coturn reads the certificate of TURNS
+const turnCertificatePath = "/etc/coturn/tls"
+
+// turnSecretVar holds the secret shared by coturn and Prosody in the secret
+// of the instance
+const turnSecretVar = "TURN_CREDENTIALS"
+
+// turnExternalIPScript starts coturn relaying on the public address of its
+// node, mapped to the address of the node. The node address is used when
+// the public one is not known yet.
+const turnExternalIPScript = `public=$$(cut -d, -f1 "$TURN_ADDRESSES_FILE" 2>/dev/null)
+exec turnserver "$@" --external-ip="$${public:-$HOST_IP}/$HOST_IP"`
+
+func turnName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-turn", jitsi.Name)
+}
+
+func turnAddressesConfigMapName(jitsi *v1alpha1.Jitsi) string {
+	return fmt.Sprintf("%s-turn-addresses", jitsi.Name)
+}
+
+// turnNodeBased reports whether coturn reads the public address of its node
+// from the addresses ConfigMap
+func turnNodeBased(jitsi *v1alpha1.Jitsi) bool {
+	turn := jitsi.ManagedTURN()
+	return turn != nil && turn.Mode == v1alpha1.TURNHostNetwork && turn.PublicIP != nil && turn.PublicIP.NodeBased()
+}
+
+// turnSyncers returns the syncers of the managed coturn of jitsi. In the
+// LoadBalancer mode coturn relays the media on the address of its load
+// balancer, pending reports that it is not known yet.
+func turnSyncers(ctx context.Context, c client.Client, jitsi *v1alpha1.Jitsi) (syncers []syncer.Interface, pending bool, err error) {
+	turn := jitsi.ManagedTURN()
+
+	if turn.Issuer != nil {
+		syncers = append(syncers, NewTURNCertificateSyncer(jitsi, c))
+	}
+
+	if turn.Mode == v1alpha1.TURNHostNetwork {
+		if turnNodeBased(jitsi) {
+			nodes, err := listNodes(ctx, c, turn.PublicIP)
+			if err != nil {
+				return nil, false, err
+			}
+			syncers = append(syncers, NewTURNAddressesConfigMapSyncer(jitsi, nodes, c))
+		}
+		return append(syncers, NewTURNDaemonSetSyncer(jitsi, c)), false, nil
+	}
+
+	svc := &corev1.Service{}
+	externalIP := ""
+	if err := c.Get(ctx, client.ObjectKey{Namespace: jitsi.Namespace, Name: turnName(jitsi)}, svc); err == nil {
+		externalIP = loadBalancerAddress(svc)
+	} else if ignoreNotFound(err) != nil {
+		return nil, false, err
+	}
+
+	return append(syncers,
+		NewTURNServiceSyncer(jitsi, c),
+		NewTURNDeploymentSyncer(jitsi, externalIP, c),
+	), len(externalIP) == 0, nil
+}
+
+func turnCertificate(jitsi *v1alpha1.Jitsi) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(certificateGVK)
+	obj.SetNamespace(jitsi.Namespace)
+	obj.SetName(turnName(jitsi))
+
+	return obj
+}
+
+// NewTURNCertificateSyncer has cert-manager issue the certificate of the
+// TURN host
+func NewTURNCertificateSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	obj := turnCertificate(jitsi)
+
+	return syncer.NewObjectSyncer("Certificate", jitsi, obj, c, func() error {
+		turn := jitsi.ManagedTURN()
+
+		obj.SetLabels(jitsi.ComponentLabels("turn"))
+
+		return unstructured.SetNestedField(obj.Object, map[string]interface{}{
+			"secretName": turn.TLSSecret,
+			"dnsNames":   []interface{}{jitsi.Spec.TURN.Host},
+			"issuerRef": map[string]interface{}{
+				"name":  turn.Issuer.Name,
+				"kind":  turn.Issuer.Kind,
+				"group": certificateGVK.Group,
+			},
+		}, "spec")
+	})
+}
+
+// NewTURNAddressesConfigMapSyncer maps the nodes to the public addresses
+// coturn relays on in the HostNetwork mode
+func NewTURNAddressesConfigMapSyncer(jitsi *v1alpha1.Jitsi, nodes []corev1.Node, c client.Client) syncer.Interface {
+	publicIP := &v1alpha1.PublicIP{}
+	if turn := jitsi.ManagedTURN(); turn != nil && turn.PublicIP != nil {
+		publicIP = turn.PublicIP
+	}
+	return newAddressesConfigMapSyncer(jitsi, turnAddressesConfigMapName(jitsi), jitsi.ComponentLabels("turn"), publicIP, nodes, c)
+}
+
+// turnServicePorts are the ports coturn listens on, the relay ports included
+func turnServicePorts(jitsi *v1alpha1.Jitsi) []corev1.ServicePort {
+	ports := []corev1.ServicePort{
+		{
+			Name:       "turn-udp",
+			Port:       v1alpha1.TURNPort,
+			TargetPort: intstr.FromInt(v1alpha1.TURNPort),
+			Protocol:   corev1.ProtocolUDP,
+		},
+		{
+			Name:       "turn-tcp",
+			Port:       v1alpha1.TURNPort,
+			TargetPort: intstr.FromInt(v1alpha1.TURNPort),
+			Protocol:   corev1.ProtocolTCP,
+		},
+	}
+
+	if jitsi.Spec.TURN.TLS {
+		ports = append(ports, corev1.ServicePort{
+			Name:       "turns",
+			Port:       int32(jitsi.Spec.TURN.Port),
+			TargetPort: intstr.FromInt(jitsi.Spec.TURN.Port),
+			Protocol:   corev1.ProtocolTCP,
+		})
+	}
+
+	first, last := jitsi.ManagedTURN().RelayPorts()
+	for port := first; port <= last; port++ {
+		ports = append(ports, corev1.ServicePort{
+			Name:       fmt.Sprintf("relay-%d", port),
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+			Protocol:   corev1.ProtocolUDP,
+		})
+	}
+
+	return ports
+}
+
+// NewTURNServiceSyncer publishes coturn and its relay ports on a load
+// balancer
+func NewTURNServiceSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      turnName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("Service", jitsi, svc, c, func() error {
+		svc.Labels = jitsi.ComponentLabels("turn")
+		svc.Annotations = jitsi.ManagedTURN().Annotations
+		svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+		svc.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+		svc.Spec.Selector = jitsi.ComponentLabels("turn")
+
+		// keep the node ports allocated to the load balancer
+		nodePorts := map[string]int32{}
+		for _, port := range svc.Spec.Ports {
+			nodePorts[port.Name] = port.NodePort
+		}
+		svc.Spec.Ports = turnServicePorts(jitsi)
+		for i := range svc.Spec.Ports {
+			svc.Spec.Ports[i].NodePort = nodePorts[svc.Spec.Ports[i].Name]
+		}
+
+		return nil
+	})
+}
+
+// turnPodSpec runs coturn authenticating the users with the secret shared
+// with Prosody. Relaying to private addresses is denied so that the TURN
+// server cannot be used to reach the cluster network.
+func turnPodSpec(jitsi *v1alpha1.Jitsi, externalIP string, template *corev1.PodTemplateSpec) {
+	turn := jitsi.ManagedTURN()
+	first, last := turn.RelayPorts()
+
+	args := []string{
+		"--log-file=stdout",
+		"--no-cli",
+		"--fingerprint",
+		"--use-auth-secret",
+		"--static-auth-secret=$(TURN_CREDENTIALS)",
+		"--realm=" + jitsi.Spec.Domain,
+		fmt.Sprintf("--listening-port=%d", v1alpha1.TURNPort),
+		fmt.Sprintf("--min-port=%d", first),
+		fmt.Sprintf("--max-port=%d", last),
+		"--no-multicast-peers",
+		"--denied-peer-ip=0.0.0.0-0.255.255.255",
+		"--denied-peer-ip=10.0.0.0-10.255.255.255",
+		"--denied-peer-ip=100.64.0.0-100.127.255.255",
+		"--denied-peer-ip=127.0.0.0-127.255.255.255",
+		"--denied-peer-ip=169.254.0.0-169.254.255.255",
+		"--denied-peer-ip=172.16.0.0-172.31.255.255",
+		"--denied-peer-ip=192.168.0.0-192.168.255.255",
+	}
+	if len(externalIP) > 0 {
+		args = append(args, "--external-ip="+externalIP)
+	}
+
+	container := corev1.Container{
+		Name:            "coturn",
+		Image:           turn.Image,
+		ImagePullPolicy: turn.ImagePullPolicy,
+		Env: []corev1.EnvVar{
+			{
+				Name: "TURN_CREDENTIALS",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: jitsi.SecretName(),
+						},
+						Key: turnSecretVar,
+					},
+				},
+			},
+		},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "turn-udp",
+				ContainerPort: v1alpha1.TURNPort,
+				Protocol:      corev1.ProtocolUDP,
+			},
+			{
+				Name:          "turn-tcp",
+				ContainerPort: v1alpha1.TURNPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{
+					Port: intstr.FromInt(v1alpha1.TURNPort),
+				},
+			},
+		},
+	}
+	template.Spec.Volumes = nil
+
+	if jitsi.Spec.TURN.TLS {
+		container.Args = append(args,
+			fmt.Sprintf("--tls-listening-port=%d", jitsi.Spec.TURN.Port),
+			"--cert="+path.Join(turnCertificatePath, corev1.TLSCertKey),
+			"--pkey="+path.Join(turnCertificatePath, corev1.TLSPrivateKeyKey),
+			"--no-tlsv1",
+			"--no-tlsv1_1",
+		)
+		container.Ports = append(container.Ports, corev1.ContainerPort{
+			Name:          "turns",
+			ContainerPort: int32(jitsi.Spec.TURN.Port),
+			Protocol:      corev1.ProtocolTCP,
+		})
+		container.VolumeMounts = []corev1.VolumeMount{
+			{
+				Name:      "tls",
+				MountPath: turnCertificatePath,
+				ReadOnly:  true,
+			},
+		}
+		template.Spec.Volumes = []corev1.Volume{
+			{
+				Name: "tls",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: turn.TLSSecret,
+					},
+				},
+			},
+		}
+	} else {
+		container.Args = append(args, "--no-tls", "--no-dtls")
+	}
+
+	if turn.Resources != nil {
+		container.Resources = *turn.Resources
+	}
+
+	template.Labels = jitsi.ComponentLabels("turn")
+	template.Spec.Containers = []corev1.Container{container}
+	template.Spec.NodeSelector = turn.NodeSelector
+	template.Spec.Tolerations = turn.Tolerations
+	injectSecretsVersion(jitsi, "turn", template)
+	injectContentVersion(jitsi, "turn", false, template)
+}
+
+// NewTURNDeploymentSyncer runs coturn behind its load balancer, relaying on
+// externalIP once it is known
+func NewTURNDeploymentSyncer(jitsi *v1alpha1.Jitsi, externalIP string, c client.Client) syncer.Interface {
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      turnName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("Deployment", jitsi, dep, c, func() error {
+		dep.Labels = jitsi.ComponentLabels("turn")
+		dep.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: dep.Labels,
+		}
+		dep.Spec.Replicas = jitsi.ManagedTURN().Replicas
+
+		turnPodSpec(jitsi, externalIP, &dep.Spec.Template)
+
+		return nil
+	})
+}
+
+// NewTURNDaemonSetSyncer runs coturn on the network of the selected nodes
+func NewTURNDaemonSetSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      turnName(jitsi),
+			Namespace: jitsi.Namespace,
+		},
+	}
+
+	return syncer.NewObjectSyncer("DaemonSet", jitsi, ds, c, func() error {
+		ds.Labels = jitsi.ComponentLabels("turn")
+		ds.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: ds.Labels,
+		}
+
+		turnPodSpec(jitsi, "", &ds.Spec.Template)
+		ds.Spec.Template.Spec.HostNetwork = true
+		ds.Spec.Template.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+		injectTURNHostAddresses(jitsi, &ds.Spec.Template)
+
+		return nil
+	})
+}
+
+// injectTURNHostAddresses makes coturn of the HostNetwork mode relay on the
+// address of its node, rather than on any address of the node the pod
+// network ones included, and advertise the public address of the node when
+// it sits behind a NAT
+func injectTURNHostAddresses(jitsi *v1alpha1.Jitsi, template *corev1.PodTemplateSpec) {
+	container := &template.Spec.Containers[0]
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name: "HOST_IP",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "status.hostIP",
+			},
+		},
+	})
+	container.Args = append(container.Args, "--relay-ip=$(HOST_IP)")
+
+	publicIP := jitsi.ManagedTURN().PublicIP
+	if publicIP == nil {
+		container.Args = append(container.Args, "--external-ip=$(HOST_IP)")
+		return
+	}
+
+	file := mountAddresses(publicIP, turnAddressesConfigMapName(jitsi), "TURN_NODE_NAME", template)
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  "TURN_ADDRESSES_FILE",
+		Value: file,
+	})
+	container.Command = []string{"sh", "-c", turnExternalIPScript, "turnserver"}
+}
+
+// turnEnvVars point Prosody at the managed coturn with the shared secret
+func turnEnvVars(jitsi *v1alpha1.Jitsi) []corev1.EnvVar {
+	envVars := []corev1.EnvVar{
+		{Name: "TURN_HOST", Value: jitsi.Spec.TURN.Host},
+		{Name: "TURN_PORT", Value: fmt.Sprint(v1alpha1.TURNPort)},
+		{
+			Name: "TURN_CREDENTIALS",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: jitsi.SecretName(),
+					},
+					Key: turnSecretVar,
+				},
+			},
+		},
+	}
+
+	if jitsi.Spec.TURN.TLS {
+		envVars = append(envVars,
+			corev1.EnvVar{Name: "TURNS_HOST", Value: jitsi.Spec.TURN.Host},
+			corev1.EnvVar{Name: "TURNS_PORT", Value: fmt.Sprint(jitsi.Spec.TURN.Port)},
+		)
+	}
+
+	return envVars
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// upgradeRetryInterval is how often a blocked upgrade is reconsidered
+const upgradeRetryInterval = 5 * time.Minute
+
+// statsTimeout is how long a rollout waits for Jicofo to report its
+// conferences, from the last report, before going on without them
+const statsTimeout = 15 * time.Minute
+
+// statsRetryInterval is how often a rollout waiting for the stats retries
+const statsRetryInterval = 30 * time.Second
+
+const (
+	reasonOutsideMaintenanceWindow = "OutsideMaintenanceWindow"
+	reasonStatsUnavailable         = "StatsUnavailable"
+)
+
+func upgradePending(jitsi *v1alpha1.Jitsi) bool {
+	return !jitsi.Spec.DisableGracefulUpgrade &&
+		jitsi.Status.LastAttemptedRevision != v1alpha1.Version &&
+		jitsi.Status.LastAppliedRevision != ""
+}
+
+func (r *JitsiReconciler) blockUpgrade(jitsi *v1alpha1.Jitsi, reason, message string) {
+	if jitsi.Status.Upgrade.Reason != reason {
+		r.event(jitsi, corev1.EventTypeNormal, "UpgradeBlocked", message)
+	}
+	jitsi.Status.Upgrade.Reason = reason
+	jitsi.Status.Upgrade.Message = message
+	jitsi.SetCondition(v1alpha1.ConditionUpgradeBlocked, metav1.ConditionTrue, reason, message)
+}
+
+// gateUpgrade decides whether a pending operator upgrade may be rolled out
+// now. It returns how long to wait before trying again when it may not.
+//
+// An upgrade waits for one of the maintenance windows to be open, then for
+// the running conferences to end. Once it has been pending for longer than
+// spec.upgrade.maxWait, it is rolled out in the next window even if
+// conferences are still running. Until Jicofo reports the conferences it
+// waits for statsTimeout at most.
+func (r *JitsiReconciler) gateUpgrade(ctx context.Context, jitsi *v1alpha1.Jitsi) (time.Duration, error) {
+	now := time.Now().In(jitsi.Location())
+
+	if jitsi.Status.Upgrade == nil || jitsi.Status.Upgrade.TargetRevision != v1alpha1.Version {
+		pendingSince := metav1.NewTime(now)
+		jitsi.Status.Upgrade = &v1alpha1.UpgradeStatus{
+			TargetRevision: v1alpha1.Version,
+			PendingSince:   &pendingSince,
+		}
+	}
+
+	policy := v1alpha1.UpgradeStrategy{}
+	if jitsi.Spec.Upgrade != nil {
+		policy = *jitsi.Spec.Upgrade
+	}
+
+	wait, next, err := maintenanceWait(&policy, now)
+	if err != nil {
+		return 0, err
+	}
+	if wait > 0 {
+		r.blockUpgrade(jitsi, reasonOutsideMaintenanceWindow,
+			fmt.Sprintf("upgrade to %s waits for the maintenance window opening at %s", v1alpha1.Version, next.Format(time.RFC3339)))
+		return wait, nil
+	}
+
+	conferences, known := r.activeConferences(ctx, jitsi)
+	if !known {
+		unknownFor := statsUnknownFor(jitsi, jitsi.Status.Upgrade.PendingSince.Time, now)
+		if unknownFor < statsTimeout {
+			r.blockUpgrade(jitsi, reasonStatsUnavailable,
+				fmt.Sprintf("upgrade to %s waits for Jicofo to report its conferences", v1alpha1.Version))
+			return statsRetryInterval, nil
+		}
+		r.event(jitsi, corev1.EventTypeWarning, "UpgradeForced",
+			fmt.Sprintf("no conference stats for %s, upgrading to %s anyway", unknownFor.Round(time.Second), v1alpha1.Version))
+		return 0, nil
+	}
+
+	if conferences > 0 {
+		pendingFor := now.Sub(jitsi.Status.Upgrade.PendingSince.Time)
+		if policy.MaxWait != nil && pendingFor >= policy.MaxWait.Duration {
+			message := fmt.Sprintf("upgrade pending for %s, forcing it with %d conferences", pendingFor.Round(time.Second), conferences)
+			r.Log.Info(message)
+			r.event(jitsi, corev1.EventTypeWarning, "UpgradeForced", message)
+			return 0, nil
+		}
+
+		if policy.Drain && jitsi.Spec.JVB.GracefulShutdown {
+			if err := r.drainBridges(ctx, jitsi); err != nil {
+				return 0, err
+			}
+			if !jitsi.Status.Upgrade.Draining {
+				r.event(jitsi, corev1.EventTypeNormal, "DrainingBridges",
+					fmt.Sprintf("rolling the bridges out to %s ahead of the other components", v1alpha1.Version))
+			}
+			jitsi.Status.Upgrade.Draining = true
+		}
+
+		r.Log.Info(fmt.Sprintf("%d conferences, requeing reconciliation", conferences))
+		r.blockUpgrade(jitsi, reasonActiveConferences,
+			fmt.Sprintf("upgrade to %s waits for %d conferences to end", v1alpha1.Version, conferences))
+		return upgradeRetryInterval, nil
+	}
+
+	return 0, nil
+}
+
+// maintenanceWait returns how long a rollout waits for one of the
+// maintenance windows of policy to open, at most upgradeRetryInterval, and
+// when the next one opens. It is 0 when a window is open or none is set.
+func maintenanceWait(policy *v1alpha1.UpgradeStrategy, now time.Time) (time.Duration, time.Time, error) {
+	if len(policy.MaintenanceWindows) == 0 {
+		return 0, time.Time{}, nil
+	}
+	window, next, err := v1alpha1.ActiveWindow(policy.MaintenanceWindows, now)
+	if err != nil || window != nil {
+		return 0, time.Time{}, err
+	}
+	if wait := next.Sub(now); wait < upgradeRetryInterval {
+		return wait, next, nil
+	}
+	return upgradeRetryInterval, next, nil
+}
+
+// activeConferences returns the number of conferences a rollout restarting
+// the components would end, known is false while Jicofo does not report them
+func (r *JitsiReconciler) activeConferences(ctx context.Context, jitsi *v1alpha1.Jitsi) (conferences int64, known bool) {
+	r.refreshStats(ctx, jitsi)
+	if stats := jitsi.Status.Stats; stats != nil && !stats.Stale {
+		return stats.Conferences, true
+	}
+	return 0, false
+}
+
+// statsUnknownFor returns for how long the conferences have not been known,
+// since the last report of Jicofo or since the rollout became pending
+func statsUnknownFor(jitsi *v1alpha1.Jitsi, pendingSince, now time.Time) time.Duration {
+	since := pendingSince
+	if stats := jitsi.Status.Stats; stats != nil && stats.LastUpdateTime != nil && stats.LastUpdateTime.After(since) {
+		since = stats.LastUpdateTime.Time
+	}
+	return now.Sub(since)
+}
+
+// drainBridges rolls the bridges out to the pending revision ahead of the
+// other components. Old bridges go through their graceful shutdown, so new
+// conferences are only allocated on upgraded bridges.
+func (r *JitsiReconciler) drainBridges(ctx context.Context, jitsi *v1alpha1.Jitsi) error {
+	target := jitsi.DeepCopy()
+	target.SetDefaults()
+	if schedule, err := target.ActiveCapacitySchedule(time.Now()); err == nil {
+		target.ApplyCapacitySchedule(schedule)
+	}
+	if _, err := r.resolveUpstream(ctx, target); err != nil {
+		return err
+	}
+	return r.sync(ctx, jvbSyncers(target, r.Client))
+}
@@ -1,8 +1,6 @@
 package controllers
 
 import (
-	"fmt"
-
 	"github.com/enna-systems/jitsi-kubernetes-operator/api/v1alpha1"
 
 	"github.com/presslabs/controller-util/pkg/syncer"
@@ -13,18 +11,18 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func NewWebServiceSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+func NewWebServiceSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-web", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "web"),
 			Namespace: jitsi.Namespace,
 		},
 	}
 
 	return syncer.NewObjectSyncer("Service", jitsi, svc, c, func() error {
-		svc.Labels = jitsi.ComponentLabels("web")
+		svc.Labels = jitsi.ShardLabels(shard, "web")
 		svc.Spec.Type = corev1.ServiceTypeClusterIP
-		svc.Spec.Selector = jitsi.ComponentLabels("web")
+		svc.Spec.Selector = jitsi.ShardLabels(shard, "web")
 		svc.Spec.Ports = []corev1.ServicePort{
 			{
 				Name: "http",
@@ -49,16 +47,16 @@ func NewWebServiceSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interfac
 
 }
 
-func NewWebDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Interface {
+func NewWebDeploymentSyncer(jitsi *v1alpha1.Jitsi, shard *v1alpha1.Shard, c client.Client) syncer.Interface {
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-web", jitsi.Name),
+			Name:      jitsi.ShardName(shard, "web"),
 			Namespace: jitsi.Namespace,
 		},
 	}
 
 	return syncer.NewObjectSyncer("Deployment", jitsi, dep, c, func() error {
-		dep.Labels = jitsi.ComponentLabels("web")
+		dep.Labels = jitsi.ShardLabels(shard, "web")
 		dep.Spec.Template.Labels = dep.Labels
 		dep.Spec.Selector = &metav1.LabelSelector{
 			MatchLabels: dep.Labels,
@@ -68,7 +66,7 @@ func NewWebDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Inter
 		dep.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
 		dep.Spec.Template.Spec.Affinity = &jitsi.Spec.Web.Affinity
 
-		envVars := append(jitsi.EnvVars(WebVariables),
+		envVars := append(jitsi.ShardEnvVars(shard, &jitsi.Spec.Web.ComponentEnv, v1alpha1.WebVariables),
 			corev1.EnvVar{
 				Name:  "COLIBRI_WEBSOCKET_REGEX",
 				Value: "[a-zA-Z0-9-\\._]+",
@@ -230,8 +228,10 @@ func NewWebDeploymentSyncer(jitsi *v1alpha1.Jitsi, c client.Client) syncer.Inter
 			})
 		}
 		dep.Spec.Template.Spec.Containers = []corev1.Container{container}
+		dep.Spec.Template.Spec.InitContainers = nil
+		injectContentVersion(jitsi, "web", false, &dep.Spec.Template)
 
-		return nil
+		return applyPodOverrides(&jitsi.Spec.Web.PodOverrides, jitsi.Spec.Web.Env, &dep.Spec.Template)
 	})
 
 }
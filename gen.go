@@ -36,11 +36,11 @@ var EXCLUDES = []string{
 	"TURNS_PORT",
 	"LOCAL_ADDRESS",
 	"COLIBRI_WEBSOCKET_REGEX",
+	"LDAP_BINDDN",
+	"LDAP_BINDPW",
 }
 
-var ADDITIONALS = map[string][]string{
-	"prosody": {"JWT_APP_SECRET"}, // TODO in a secret
-}
+var ADDITIONALS = map[string][]string{}
 
 type Compose struct {
 	Services map[string]ComposeService
@@ -56,7 +56,7 @@ type Environment struct {
 }
 
 var Template = template.Must(template.New("").Parse(`// Code generated by go generate; DO NOT EDIT.
-package controllers
+package v1alpha1
 {{ range . }}
 var {{ .Name }}Variables = []string{
 {{- range .Variables }}
@@ -100,7 +100,7 @@ func main() {
 	sort.SliceStable(environments, func(i, j int) bool {
 		return environments[i].Name < environments[j].Name
 	})
-	f, err := os.Create("controllers/environments.go")
+	f, err := os.Create("api/v1alpha1/environments.go")
 	handleErr(err)
 	defer f.Close()
 	Template.Execute(f, environments)